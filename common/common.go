@@ -6,10 +6,12 @@
 package common
 
 import (
+	"bufio"
 	"embed"
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 	"unicode/utf8"
 
@@ -66,24 +68,28 @@ func IsValidEmail(mail string) bool {
 	return strings.Contains(mail, "@")
 }
 
+// Reader of the responses to the questions
+var stdin = bufio.NewReader(os.Stdin)
+
+// Read a line of the standard input, without the line break
+func readLine() string {
+	response, _ := stdin.ReadString('\n')
+	return strings.TrimSpace(response)
+}
+
 // Confirm a question
 func Confirm(question string) bool {
-	var response string
-
 	fmt.Printf("%s [%s/%s] ", question, T("questions.y"), T("questions.n"))
-	fmt.Scanln(&response)
+	response := readLine()
 
 	return strings.ToLower(response) == T("questions.y")
 }
 
 // Ask a question and return the response
+// The whole line is returned, so that the response can contain spaces
 func Ask(question string) string {
-	var response string
-
 	fmt.Printf("%s : ", question)
-	fmt.Scanln(&response)
-
-	return response
+	return readLine()
 }
 
 // Print an example command line
@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Changes of the access rights of an organization, a workspace or a document
+type AccessDelta struct {
+	// Maximum role inherited from the parent ("owners", "editors", "viewers",
+	// or "" for no inheritance), unchanged if nil
+	MaxInheritedRole *string
+	// Role of each user, by email (nil to remove the access of the user)
+	Users map[string]*string
+}
+
+func (d AccessDelta) MarshalJSON() ([]byte, error) {
+	delta := map[string]any{}
+	if d.MaxInheritedRole != nil {
+		if *d.MaxInheritedRole == "" {
+			delta["maxInheritedRole"] = nil
+		} else {
+			delta["maxInheritedRole"] = *d.MaxInheritedRole
+		}
+	}
+	if len(d.Users) > 0 {
+		delta["users"] = d.Users
+	}
+	return json.Marshal(map[string]any{"delta": delta})
+}
+
+// Changes the access rights of an organization
+func (c *Client) UpdateOrgAccess(ctx context.Context, orgId int, delta AccessDelta) error {
+	return c.sendJSON(ctx, "PATCH", fmt.Sprintf("orgs/%d/access", orgId), delta, nil)
+}
+
+// Changes the access rights of a workspace
+func (c *Client) UpdateWorkspaceAccess(ctx context.Context, workspaceId int, delta AccessDelta) error {
+	return c.sendJSON(ctx, "PATCH", fmt.Sprintf("workspaces/%d/access", workspaceId), delta, nil)
+}
+
+// Changes the access rights of a document
+func (c *Client) UpdateDocAccess(ctx context.Context, docId string, delta AccessDelta) error {
+	return c.sendJSON(ctx, "PATCH", "docs/"+docId+"/access", delta, nil)
+}
@@ -0,0 +1,233 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Default User-Agent sent to the Grist server
+const DefaultUserAgent = "gristctl"
+
+// Client of a Grist instance's REST API
+type Client struct {
+	baseURL    string
+	token      string
+	userAgent  string
+	timeout    time.Duration
+	retry      RetryPolicy
+	httpClient *http.Client
+}
+
+// Client configuration option, to be passed to NewClient
+type Option func(*Client)
+
+// Use a specific HTTP client to send requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// Limit the duration of each request (0 means no limit)
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// Set the User-Agent header sent with each request
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// Override the URL of the Grist server (without /api)
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// Create a client for the Grist server at baseURL (without /api),
+// authenticated with the token (API key) of a user
+func NewClient(baseURL string, token string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		userAgent:  DefaultUserAgent,
+		retry:      DefaultRetryPolicy,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.timeout > 0 {
+		// Copy the HTTP client so that a shared one is not altered
+		httpClient := *c.httpClient
+		httpClient.Timeout = c.timeout
+		c.httpClient = &httpClient
+	}
+	return c
+}
+
+// Create a client configured with the GRIST_URL and GRIST_TOKEN
+// environment variables
+func NewClientFromEnv(opts ...Option) *Client {
+	return NewClient(os.Getenv("GRIST_URL"), os.Getenv("GRIST_TOKEN"), opts...)
+}
+
+// Returns the URL of the Grist server
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Returns true if the client has a token to authenticate with
+func (c *Client) HasToken() bool {
+	return c.token != ""
+}
+
+// Sending an HTTP request to Grist's REST API
+// Action: GET, POST, PATCH, DELETE
+// contentType is the type of data (application/json if empty)
+// The request is retried according to the retry policy of the client
+// Returns the response, whose body has to be closed by the caller,
+// or an *APIError if Grist answered with an error status
+func (c *Client) sendRequest(ctx context.Context, action string, myRequest string, contentType string, data []byte) (*http.Response, error) {
+	body := func() (io.Reader, error) {
+		return bytes.NewReader(data), nil
+	}
+	return c.sendBody(ctx, action, myRequest, contentType, body, true)
+}
+
+// Sends an HTTP request whose body is returned by body, called for each attempt
+// The request is only retried if rewindable is true, body being able to return
+// the whole content again
+func (c *Client) sendBody(ctx context.Context, action string, myRequest string, contentType string, body func() (io.Reader, error), rewindable bool) (*http.Response, error) {
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	url := fmt.Sprintf("%s/api/%s", c.baseURL, myRequest)
+	bearer := "Bearer " + c.token
+
+	for attempt := 1; ; attempt++ {
+		canRetry := attempt < c.retry.MaxAttempts && rewindable
+
+		content, err := body()
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, action, url, content)
+		if err != nil {
+			if closer, ok := content.(io.Closer); ok {
+				closer.Close()
+			}
+			return nil, fmt.Errorf("error creating request %s: %w", url, err)
+		}
+		req.Header.Add("Authorization", bearer)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("User-Agent", c.userAgent)
+
+		// Send the HTTP request
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			if canRetry && ctx.Err() == nil && isRetryableError(action, err) {
+				if err := sleep(ctx, c.retry.backoff(attempt, 0)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			return nil, fmt.Errorf("error sending request %s: %w", url, err)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if canRetry && isRetryableStatus(action, resp.StatusCode) {
+				if err := sleep(ctx, c.retry.backoff(attempt, parseRetryAfter(resp))); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			return nil, newAPIError(action, myRequest, resp.StatusCode, body)
+		}
+		return resp, nil
+	}
+}
+
+// Sending an HTTP request to Grist's REST API
+// Action: GET, POST, PATCH, DELETE
+// Returns response body, or an *APIError if Grist answered with an error status
+func (c *Client) httpRequest(ctx context.Context, action string, myRequest string, data []byte) ([]byte, error) {
+	resp, err := c.sendRequest(ctx, action, myRequest, "", data)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Read the HTTP response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response %s: %w", myRequest, err)
+	}
+	return body, nil
+}
+
+// Send an HTTP GET request to Grist's REST API
+// Returns the response body
+func (c *Client) httpGet(ctx context.Context, myRequest string, data string) ([]byte, error) {
+	return c.httpRequest(ctx, "GET", myRequest, []byte(data))
+}
+
+// Sends an HTTP POST request to Grist's REST API with a data load
+// Return the response body
+func (c *Client) httpPost(ctx context.Context, myRequest string, data string) ([]byte, error) {
+	return c.httpRequest(ctx, "POST", myRequest, []byte(data))
+}
+
+// Sends an HTTP PATCH request to Grist's REST API with a data load
+// Return the response body
+func (c *Client) httpPatch(ctx context.Context, myRequest string, data string) ([]byte, error) {
+	return c.httpRequest(ctx, "PATCH", myRequest, []byte(data))
+}
+
+// Sends an HTTP PUT request to Grist's REST API with a data load
+// Return the response body
+func (c *Client) httpPut(ctx context.Context, myRequest string, data string) ([]byte, error) {
+	return c.httpRequest(ctx, "PUT", myRequest, []byte(data))
+}
+
+// Send an HTTP DELETE request to Grist's REST API with a data load
+// Return the response body
+func (c *Client) httpDelete(ctx context.Context, myRequest string, data string) ([]byte, error) {
+	return c.httpRequest(ctx, "DELETE", myRequest, []byte(data))
+}
+
+// Send an HTTP GET request to Grist's REST API
+// and decode the JSON response into result
+func (c *Client) getJSON(ctx context.Context, myRequest string, result any) error {
+	response, err := c.httpGet(ctx, myRequest, "")
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(response, result); err != nil {
+		return fmt.Errorf("error decoding response of %s: %w", myRequest, err)
+	}
+	return nil
+}
+
+// Test Grist API connection
+func (c *Client) TestConnection(ctx context.Context) bool {
+	_, err := c.httpGet(ctx, "orgs", "")
+	return err == nil
+}
@@ -0,0 +1,266 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// Name of the profile used when none is defined
+const DefaultProfile = "default"
+
+// Connection profile to a Grist instance
+type Profile struct {
+	Name         string `json:"name"`
+	URL          string `json:"url"`
+	Token        string `json:"-"`                      // Token written in the configuration file
+	TokenCommand string `json:"tokenCommand,omitempty"` // Command printing the token (e.g. `pass show grist/prod`)
+	TokenStore   string `json:"tokenStore,omitempty"`   // Name of the secret store holding the token (e.g. "keyring")
+}
+
+/*
+Content of the configuration file
+
+The file contains the name of the current profile, followed by one section per profile:
+
+	CURRENT_PROFILE="prod"
+
+	[prod]
+	GRIST_URL="https://grist.example.com"
+	GRIST_TOKEN="..."
+
+Instead of GRIST_TOKEN, a profile can define GRIST_TOKEN_COMMAND, a command
+printing the token, or GRIST_TOKEN_STORE, the secret store holding the token.
+
+A file without section (previous format) defines the "default" profile.
+The file is written with 0600 permissions, as it may contain tokens.
+*/
+type Config struct {
+	Current  string    // Name of the profile used by default
+	Profiles []Profile // Profiles, in the order of the file
+}
+
+// Header of a profile section
+var sectionRegexp = regexp.MustCompile(`^\s*\[(.*)\]\s*$`)
+
+// Allowed profile names
+var profileNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Returns the path of the configuration file
+func ConfigPath() string {
+	home := os.Getenv("HOME")
+	return filepath.Join(home, ".gristctl")
+}
+
+// Check that a profile name can be used in the configuration file
+func ValidateProfileName(name string) error {
+	if !profileNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid profile name '%s' (allowed characters: letters, digits, '_', '.' and '-')", name)
+	}
+	return nil
+}
+
+// Read the configuration file
+// A missing file gives an empty configuration
+func LoadConfig(fileName string) (*Config, error) {
+	config := &Config{}
+	content, err := os.ReadFile(fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+
+	// Split the file in sections, the first one (without name) being the header
+	names := []string{""}
+	sections := []string{""}
+	for _, line := range strings.Split(string(content), "\n") {
+		if match := sectionRegexp.FindStringSubmatch(line); match != nil {
+			names = append(names, strings.TrimSpace(match[1]))
+			sections = append(sections, "")
+		} else {
+			sections[len(sections)-1] += line + "\n"
+		}
+	}
+
+	for i, section := range sections {
+		values, err := godotenv.Unmarshal(section)
+		if err != nil {
+			return config, fmt.Errorf("error reading configuration file %s: %w", fileName, err)
+		}
+		if i == 0 {
+			config.Current = values["CURRENT_PROFILE"]
+			// Previous format, without profile
+			if values["GRIST_URL"] != "" || values["GRIST_TOKEN"] != "" {
+				config.SetProfile(Profile{Name: DefaultProfile, URL: values["GRIST_URL"], Token: values["GRIST_TOKEN"]})
+			}
+		} else {
+			config.SetProfile(Profile{
+				Name:         names[i],
+				URL:          values["GRIST_URL"],
+				Token:        values["GRIST_TOKEN"],
+				TokenCommand: values["GRIST_TOKEN_COMMAND"],
+				TokenStore:   values["GRIST_TOKEN_STORE"],
+			})
+		}
+	}
+	return config, nil
+}
+
+// Write the configuration file
+func (c *Config) Save(fileName string) error {
+	var content strings.Builder
+	if c.Current != "" {
+		header, _ := godotenv.Marshal(map[string]string{"CURRENT_PROFILE": c.Current})
+		content.WriteString(header + "\n")
+	}
+	for _, profile := range c.Profiles {
+		values := map[string]string{"GRIST_URL": profile.URL}
+		switch {
+		case profile.TokenCommand != "":
+			values["GRIST_TOKEN_COMMAND"] = profile.TokenCommand
+		case profile.TokenStore != "":
+			values["GRIST_TOKEN_STORE"] = profile.TokenStore
+		}
+		// With a command or a store, the token of the file is a fallback
+		if profile.Token != "" || (profile.TokenCommand == "" && profile.TokenStore == "") {
+			values["GRIST_TOKEN"] = profile.Token
+		}
+		lines, _ := godotenv.Marshal(values)
+		fmt.Fprintf(&content, "\n[%s]\n%s\n", profile.Name, lines)
+	}
+	if err := os.WriteFile(fileName, []byte(content.String()), 0600); err != nil {
+		return err
+	}
+	// The permissions of an existing file are not changed by WriteFile
+	return os.Chmod(fileName, 0600)
+}
+
+// Returns true if the configuration file can be read by other users
+func IsConfigReadableByOthers(fileName string) (bool, error) {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return false, err
+	}
+	// File permissions are not meaningful on Windows
+	if runtime.GOOS == "windows" {
+		return false, nil
+	}
+	return info.Mode().Perm()&0o044 != 0, nil
+}
+
+// Returns the name of the profile used by default
+func (c *Config) CurrentName() string {
+	if c.Current != "" {
+		return c.Current
+	}
+	return DefaultProfile
+}
+
+// Returns the profile with the given name
+func (c *Config) Profile(name string) (Profile, bool) {
+	for _, profile := range c.Profiles {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+	return Profile{Name: name}, false
+}
+
+// Add a profile, or replace the profile with the same name
+func (c *Config) SetProfile(profile Profile) {
+	for i := range c.Profiles {
+		if c.Profiles[i].Name == profile.Name {
+			c.Profiles[i] = profile
+			return
+		}
+	}
+	c.Profiles = append(c.Profiles, profile)
+}
+
+// Remove a profile
+// Returns false if the profile does not exist
+func (c *Config) RemoveProfile(name string) bool {
+	for i, profile := range c.Profiles {
+		if profile.Name == name {
+			c.Profiles = append(c.Profiles[:i], c.Profiles[i+1:]...)
+			if c.Current == name {
+				c.Current = ""
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the connection profile to use (see FindProfile), with its token
+// With a *TokenFallbackError, the profile has the token of the configuration file
+func LoadProfile(name string) (Profile, error) {
+	profile, err := FindProfile(name)
+	if err != nil {
+		return profile, err
+	}
+	profile.Token, err = profile.GetToken()
+	return profile, err
+}
+
+/*
+Returns the connection profile to use, without running its token command
+or reading its secret store, which is, by order of priority:
+  - the profile named name, if not empty
+  - the profile named by the GRIST_PROFILE environment variable
+  - the GRIST_URL and GRIST_TOKEN environment variables, if they are set
+  - the current profile of the configuration file
+*/
+func FindProfile(name string) (Profile, error) {
+	if name == "" {
+		name = os.Getenv("GRIST_PROFILE")
+	}
+	if name == "" && os.Getenv("GRIST_URL") != "" && os.Getenv("GRIST_TOKEN") != "" {
+		return Profile{URL: os.Getenv("GRIST_URL"), Token: os.Getenv("GRIST_TOKEN")}, nil
+	}
+
+	configFile := ConfigPath()
+	config, err := LoadConfig(configFile)
+	if err != nil {
+		return Profile{}, err
+	}
+	explicit := name != ""
+	if !explicit {
+		name = config.CurrentName()
+	}
+	profile, found := config.Profile(name)
+	if !found && explicit {
+		return profile, fmt.Errorf("profile '%s' not found in %s", name, configFile)
+	}
+	return profile, nil
+}
+
+// Apply config and return the config file path
+// The GRIST_URL and GRIST_TOKEN environment variables are set from the profile
+// selected by GRIST_PROFILE (or the current one) if they are not already set
+func GetConfig() string {
+	profile, err := LoadProfile("")
+	if err != nil && !IsTokenFallback(err) {
+		fmt.Printf("Error reading configuration file : %s\n", err)
+	}
+	if os.Getenv("GRIST_URL") == "" {
+		os.Setenv("GRIST_URL", profile.URL)
+	}
+	if os.Getenv("GRIST_TOKEN") == "" {
+		os.Setenv("GRIST_TOKEN", profile.Token)
+	}
+	return ConfigPath()
+}
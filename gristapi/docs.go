@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Sends a request with a JSON body, and decodes the JSON response into result (if not nil)
+func (c *Client) sendJSON(ctx context.Context, action string, myRequest string, body any, result any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	response, err := c.httpRequest(ctx, action, myRequest, data)
+	if err != nil {
+		return err
+	}
+	if result != nil {
+		if err := json.Unmarshal(response, result); err != nil {
+			return fmt.Errorf("error decoding response of %s: %w", myRequest, err)
+		}
+	}
+	return nil
+}
+
+// Creates an empty document in a workspace
+// Returns the id of the new document
+func (c *Client) CreateDoc(ctx context.Context, workspaceId int, name string) (string, error) {
+	docId := ""
+	url := fmt.Sprintf("workspaces/%d/docs", workspaceId)
+	err := c.sendJSON(ctx, "POST", url, map[string]any{"name": name}, &docId)
+	return docId, err
+}
+
+// Renames a document
+func (c *Client) RenameDoc(ctx context.Context, docId string, name string) error {
+	return c.sendJSON(ctx, "PATCH", "docs/"+docId, map[string]any{"name": name}, nil)
+}
+
+// Copies a document into a workspace, under a new name
+// With asTemplate, only the structure of the document is copied, without its data and history
+// Returns the id of the new document
+func (c *Client) CopyDoc(ctx context.Context, docId string, workspaceId int, name string, asTemplate bool) (string, error) {
+	newDocId := ""
+	body := map[string]any{"workspaceId": workspaceId, "documentName": name, "asTemplate": asTemplate}
+	err := c.sendJSON(ctx, "POST", "docs/"+docId+"/copy", body, &newDocId)
+	return newDocId, err
+}
+
+// Moves a document to another workspace of the same organization
+func (c *Client) MoveDoc(ctx context.Context, docId string, workspaceId int) error {
+	return c.sendJSON(ctx, "PATCH", "docs/"+docId+"/move", map[string]any{"workspace": workspaceId}, nil)
+}
+
+// Replaces the content of a document with the content of another document
+// The history of the source document is not kept
+func (c *Client) ReplaceDoc(ctx context.Context, docId string, sourceDocId string) error {
+	return c.sendJSON(ctx, "POST", "docs/"+docId+"/replace", map[string]any{"sourceDocId": sourceDocId}, nil)
+}
@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+)
+
+// First bytes of the files exported by Grist
+var (
+	magicGrist = []byte("SQLite format 3\x00") // .grist files are SQLite databases
+	magicExcel = []byte("PK\x03\x04")          // .xlsx files are ZIP archives
+)
+
+// Writer checking that the first bytes written match an expected signature
+type magicWriter struct {
+	w       io.Writer
+	magic   []byte
+	checked int // Number of bytes of the signature already checked
+}
+
+func (m *magicWriter) Write(p []byte) (int, error) {
+	if m.checked < len(m.magic) {
+		n := min(len(p), len(m.magic)-m.checked)
+		if !bytes.Equal(p[:n], m.magic[m.checked:m.checked+n]) {
+			return 0, fmt.Errorf("downloaded content is not in the expected format")
+		}
+		m.checked += n
+	}
+	return m.w.Write(p)
+}
+
+// Download a file and check that it starts with the expected signature
+func (c *Client) downloadFile(ctx context.Context, myRequest string, w io.Writer, magic []byte) (int64, error) {
+	mw := &magicWriter{w: w, magic: magic}
+	size, err := c.download(ctx, myRequest, mw)
+	if err == nil && mw.checked < len(magic) {
+		err = fmt.Errorf("downloaded content of %s is too short (%d bytes)", myRequest, size)
+	}
+	return size, err
+}
+
+// Download a file from Grist's REST API and copy it to w as it is received
+// Returns the number of bytes written
+// The content type and size announced by the server are checked
+func (c *Client) download(ctx context.Context, myRequest string, w io.Writer) (int64, error) {
+	resp, err := c.sendRequest(ctx, "GET", myRequest, "", nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// An HTML or JSON response is an error or login page, not an export
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/html", "application/json":
+		return 0, fmt.Errorf("unexpected content type %s for %s", mediaType, myRequest)
+	}
+
+	size, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return size, fmt.Errorf("error downloading %s: %w", myRequest, err)
+	}
+	if resp.ContentLength >= 0 && size != resp.ContentLength {
+		return size, fmt.Errorf("incomplete download of %s: %d bytes received out of %d", myRequest, size, resp.ContentLength)
+	}
+	return size, nil
+}
+
+// Export doc in Grist format (Sqlite) to w
+// Returns the number of bytes written
+func (c *Client) ExportDocGrist(ctx context.Context, docId string, w io.Writer) (int64, error) {
+	url := fmt.Sprintf("docs/%s/download", docId)
+	return c.downloadFile(ctx, url, w, magicGrist)
+}
+
+// Export doc in Excel format (XLSX) to w
+// Returns the number of bytes written
+func (c *Client) ExportDocExcel(ctx context.Context, docId string, w io.Writer) (int64, error) {
+	url := fmt.Sprintf("docs/%s/download/xlsx", docId)
+	return c.downloadFile(ctx, url, w, magicExcel)
+}
+
+// Save a download in fileName
+// The content is written in a temporary file of the same directory,
+// which is renamed to fileName only if the download is complete
+func SaveToFile(fileName string, download func(io.Writer) (int64, error)) (int64, error) {
+	tmpFile, err := os.CreateTemp(filepath.Dir(fileName), "."+filepath.Base(fileName)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	// Without effect once the file has been renamed
+	defer os.Remove(tmpFile.Name())
+
+	if err := tmpFile.Chmod(0644); err != nil {
+		tmpFile.Close()
+		return 0, err
+	}
+	size, err := download(tmpFile)
+	if err != nil {
+		tmpFile.Close()
+		return size, err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return size, err
+	}
+	if err := os.Rename(tmpFile.Name(), fileName); err != nil {
+		return size, err
+	}
+	return size, nil
+}
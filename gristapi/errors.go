@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error returned by Grist's REST API
+type APIError struct {
+	StatusCode int    // HTTP status code
+	Method     string // HTTP method of the request
+	Endpoint   string // Requested endpoint, relative to /api
+	Message    string // Error message returned by Grist
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s %s : %d %s", e.Method, e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += " (" + e.Message + ")"
+	}
+	return msg
+}
+
+// Build the error corresponding to an unsuccessful response
+// Grist returns errors as {"error": "message", "details": ...}
+func newAPIError(method string, endpoint string, statusCode int, body []byte) *APIError {
+	var gristError struct {
+		Error string `json:"error"`
+	}
+	message := ""
+	if json.Unmarshal(body, &gristError) == nil && gristError.Error != "" {
+		message = gristError.Error
+	} else {
+		message = strings.TrimSpace(string(body))
+	}
+	return &APIError{
+		StatusCode: statusCode,
+		Method:     method,
+		Endpoint:   endpoint,
+		Message:    message,
+	}
+}
+
+// Returns the HTTP status code of an API error, or 0 for other errors
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// Returns true if the error means that the requested entity does not exist
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// Returns true if the error means that the user is not allowed to perform the request
+func IsForbidden(err error) bool {
+	return StatusCode(err) == http.StatusForbidden
+}
+
+// Returns true if the error means that the token was rejected
+func IsUnauthorized(err error) bool {
+	return StatusCode(err) == http.StatusUnauthorized
+}
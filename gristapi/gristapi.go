@@ -7,17 +7,10 @@ package gristapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
-	"strings"
-
-	"github.com/joho/godotenv"
 )
 
 // Grist's user
@@ -62,6 +55,7 @@ type Workspace struct {
 	Id                 int    `json:"id"`
 	Name               string `json:"name"`
 	CreatedAt          string `json:"createdAt"`
+	RemovedAt          string `json:"removedAt,omitempty"` // Date of the move to the trash
 	Docs               []Doc  `json:"docs"`
 	IsSupportWorkspace string `json:"isSupportWorkspace"`
 	OrgDomain          string `json:"orgDomain"`
@@ -79,6 +73,9 @@ type Doc struct {
 	Id        string    `json:"id"`
 	Name      string    `json:"name"`
 	IsPinned  bool      `json:"isPinned"`
+	CreatedAt string    `json:"createdAt"`
+	UpdatedAt string    `json:"updatedAt"`
+	RemovedAt string    `json:"removedAt,omitempty"` // Date of the move to the trash
 	Workspace Workspace `json:"workspace"`
 }
 
@@ -93,13 +90,75 @@ type Tables struct {
 }
 
 // Grist's table column
-type TableColumn struct {
-	Id string `json:"id"`
+type Column struct {
+	Id     string       `json:"id" yaml:"id"`
+	Fields ColumnFields `json:"fields" yaml:"fields"`
+}
+
+// Properties of a column
+// Empty properties are left unchanged when a column is modified, unless they are cleared
+type ColumnFields struct {
+	Type          string   `json:"type,omitempty" yaml:"type,omitempty"`                   // Text, Numeric, Int, Bool, Date, DateTime:<tz>, Ref:<table>...
+	Label         string   `json:"label,omitempty" yaml:"label,omitempty"`                 // Label displayed in Grist (the id by default)
+	Formula       string   `json:"formula,omitempty" yaml:"formula,omitempty"`             // Python formula
+	IsFormula     *bool    `json:"isFormula,omitempty" yaml:"isFormula,omitempty"`         // Formula column, or data column (with an optional trigger formula)
+	WidgetOptions string   `json:"widgetOptions,omitempty" yaml:"widgetOptions,omitempty"` // Display options (choices, format...), as a JSON object
+	Description   string   `json:"description,omitempty" yaml:"description,omitempty"`     // Description of the column
+	Cleared       []string `json:"-" yaml:"-"`                                             // Properties set to an empty value when the column is modified (e.g. "formula")
+}
+
+// Encodes the properties of a column, the empty ones being omitted unless they are cleared
+func (f ColumnFields) MarshalJSON() ([]byte, error) {
+	type fields ColumnFields
+	content, err := json.Marshal(fields(f))
+	if err != nil || len(f.Cleared) == 0 {
+		return content, err
+	}
+	values := map[string]any{}
+	if err := json.Unmarshal(content, &values); err != nil {
+		return nil, err
+	}
+	for _, name := range f.Cleared {
+		if _, found := values[name]; !found {
+			values[name] = ""
+		}
+	}
+	return json.Marshal(values)
+}
+
+// Decodes the properties of a column, the widget options being given as a JSON string,
+// or as an object (e.g. written as a map in a YAML file)
+func (f *ColumnFields) UnmarshalJSON(content []byte) error {
+	type fields ColumnFields
+	var decoded struct {
+		fields
+		WidgetOptions json.RawMessage `json:"widgetOptions"`
+	}
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		return err
+	}
+	*f = ColumnFields(decoded.fields)
+	options := bytes.TrimSpace(decoded.WidgetOptions)
+	switch {
+	case len(options) == 0 || string(options) == "null":
+		f.WidgetOptions = ""
+	case options[0] == '"':
+		return json.Unmarshal(options, &f.WidgetOptions)
+	case options[0] == '{':
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, options); err != nil {
+			return err
+		}
+		f.WidgetOptions = compact.String()
+	default:
+		return fmt.Errorf("invalid widgetOptions %s, expected an object or a JSON string", options)
+	}
+	return nil
 }
 
 // List of Grist's table columns
 type TableColumns struct {
-	Columns []TableColumn `json:"columns"`
+	Columns []Column `json:"columns"`
 }
 
 // Grist's table row
@@ -113,363 +172,207 @@ type UserRole struct {
 	Role  string
 }
 
-// Apply config and return the config file path
-func GetConfig() string {
-	home := os.Getenv("HOME")
-	configFile := filepath.Join(home, ".gristctl")
-	if os.Getenv("GRIST_TOKEN") == "" || os.Getenv("GRIST_URL") == "" {
-		err := godotenv.Load(configFile)
-		if err != nil {
-			fmt.Printf("Error reading configuration file : %s\n", err)
-		}
-	}
-	return configFile
-}
-
-func init() {
-	GetConfig()
-}
-
-// Sending an HTTP request to Grist's REST API
-// Action: GET, POST, PATCH, DELETE
-// Returns response body
-func httpRequest(action string, myRequest string, data *bytes.Buffer) (string, int) {
-	client := &http.Client{}
-	url := fmt.Sprintf("%s/api/%s", os.Getenv("GRIST_URL"), myRequest)
-	bearer := "Bearer " + os.Getenv("GRIST_TOKEN")
-
-	req, err := http.NewRequest(action, url, data)
-	if err != nil {
-		log.Fatalf("Error creating request %s: %s", url, err)
-	}
-	req.Header.Add("Authorization", bearer)
-	req.Header.Set("Content-Type", "application/json")
-
-	// Send the HTTP request
-	resp, err := client.Do(req)
-	if err != nil {
-		errMsg := fmt.Sprintf("Error sending request %s: %s", url, err)
-		return errMsg, -10
-	} else {
-		defer resp.Body.Close()
-		// Read the HTTP response body
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Printf("Error reading response %s: %s", url, err)
-		}
-		return string(body), resp.StatusCode
-	}
-}
-
-// Send an HTTP GET request to Grist's REST API
-// Returns the response body
-func httpGet(myRequest string, data string) (string, int) {
-	dataBody := bytes.NewBuffer([]byte(data))
-	body, status := httpRequest("GET", myRequest, dataBody)
-	// if status != http.StatusOK {
-	// 	fmt.Printf("Return code from %s : %d (%s)\n", myRequest, status, body)
-	// }
-	return body, status
-}
-
-// Test Grist API connection
-func TestConnection() bool {
-	_, status := httpGet("orgs", "")
-	return status == http.StatusOK
-}
-
-// Sends an HTTP POST request to Grist's REST API with a data load
-// Return the response body
-func httpPost(myRequest string, data string) (string, int) {
-	dataBody := bytes.NewBuffer([]byte(data))
-	body, status := httpRequest("POST", myRequest, dataBody)
-	return body, status
-}
-
-// Sends an HTTP POST request to Grist's REST API with a data load
-// Return the response body
-func httpPatch(myRequest string, data string) (string, int) {
-	dataBody := bytes.NewBuffer([]byte(data))
-	body, status := httpRequest("PATCH", myRequest, dataBody)
-	return body, status
-}
-
-// Send an HTTP DELETE request to Grist's REST API with a data load
-// Return the response body
-func httpDelete(myRequest string, data string) (string, int) {
-	dataBody := bytes.NewBuffer([]byte(data))
-	body, status := httpRequest("DELETE", myRequest, dataBody)
-	return body, status
-}
-
 // Retrieves the list of organizations
-func GetOrgs() []Org {
+func (c *Client) GetOrgs(ctx context.Context) ([]Org, error) {
 	myOrgs := []Org{}
-	response, _ := httpGet("orgs", "")
-	json.Unmarshal([]byte(response), &myOrgs)
-	return myOrgs
+	err := c.getJSON(ctx, "orgs", &myOrgs)
+	return myOrgs, err
 }
 
 // Retrieves the organization whose identifier is passed in parameter
-func GetOrg(idOrg string) Org {
+func (c *Client) GetOrg(ctx context.Context, idOrg string) (Org, error) {
 	myOrg := Org{}
-	response, _ := httpGet("orgs/"+idOrg, "")
-	json.Unmarshal([]byte(response), &myOrg)
-	return myOrg
+	err := c.getJSON(ctx, "orgs/"+idOrg, &myOrg)
+	return myOrg, err
 }
 
 // Retrieves the list of users in the organization whose ID is passed in parameter
-func GetOrgAccess(idOrg string) []User {
+func (c *Client) GetOrgAccess(ctx context.Context, idOrg string) ([]User, error) {
 	var lstUsers EntityAccess
 	url := fmt.Sprintf("orgs/%s/access", idOrg)
-	response, _ := httpGet(url, "")
-	json.Unmarshal([]byte(response), &lstUsers)
-	return lstUsers.Users
+	err := c.getJSON(ctx, url, &lstUsers)
+	return lstUsers.Users, err
 }
 
 // Retrieves information on a specific organization
-func GetOrgWorkspaces(orgId int) []Workspace {
+func (c *Client) GetOrgWorkspaces(ctx context.Context, orgId int) ([]Workspace, error) {
 	lstWorkspaces := []Workspace{}
-	response, _ := httpGet("orgs/"+strconv.Itoa(orgId)+"/workspaces", "")
-	json.Unmarshal([]byte(response), &lstWorkspaces)
-	return lstWorkspaces
+	err := c.getJSON(ctx, "orgs/"+strconv.Itoa(orgId)+"/workspaces", &lstWorkspaces)
+	return lstWorkspaces, err
 }
 
 // Get a workspace
-func GetWorkspace(workspaceId int) Workspace {
+func (c *Client) GetWorkspace(ctx context.Context, workspaceId int) (Workspace, error) {
 	workspace := Workspace{}
 	url := fmt.Sprintf("workspaces/%d", workspaceId)
-	response, returnCode := httpGet(url, "")
-	if returnCode == http.StatusOK {
-		json.Unmarshal([]byte(response), &workspace)
-	}
-	return workspace
+	err := c.getJSON(ctx, url, &workspace)
+	return workspace, err
 }
 
 // Delete a workspace
-func DeleteWorkspace(workspaceId int) {
+func (c *Client) DeleteWorkspace(ctx context.Context, workspaceId int) error {
 	url := fmt.Sprintf("workspaces/%d", workspaceId)
-	response, status := httpDelete(url, "")
-	if status == http.StatusOK {
-		fmt.Printf("Workspace %d deleted\t✅\n", workspaceId)
-	} else {
-		fmt.Printf("Unable to delete workspace %d : %s ❗️\n", workspaceId, response)
-	}
+	_, err := c.httpDelete(ctx, url, "")
+	return err
 }
 
 // Delete a document
-func DeleteDoc(docId string) {
+func (c *Client) DeleteDoc(ctx context.Context, docId string) error {
 	url := fmt.Sprintf("docs/%s", docId)
-	response, status := httpDelete(url, "")
-	if status == http.StatusOK {
-		fmt.Printf("Document %s deleted\t✅\n", docId)
-	} else {
-		fmt.Printf("Unable to delete document %s : %s ❗️", docId, response)
-	}
+	_, err := c.httpDelete(ctx, url, "")
+	return err
 }
 
 // Delete a user
-func DeleteUser(userId int) {
+func (c *Client) DeleteUser(ctx context.Context, userId int) error {
 	url := fmt.Sprintf("users/%d", userId)
-	response, status := httpDelete(url, `{"name": ""}`)
-
-	var message string
-	switch status {
-	case 200:
-		message = "The account has been deleted successfully"
-	case 400:
-		message = "The passed user name does not match the one retrieved from the database given the passed user id"
-	case 403:
-		message = "The caller is not allowed to delete this account"
-	case 404:
-		message = "The user is not found"
-	}
-	fmt.Println(message)
-	if status != http.StatusOK {
-		fmt.Printf("ERREUR: %s\n", response)
-	}
+	_, err := c.httpDelete(ctx, url, `{"name": ""}`)
+	return err
 }
 
 // Workspace access rights query
-func GetWorkspaceAccess(workspaceId int) EntityAccess {
+func (c *Client) GetWorkspaceAccess(ctx context.Context, workspaceId int) (EntityAccess, error) {
 	workspaceAccess := EntityAccess{}
 	url := fmt.Sprintf("workspaces/%d/access", workspaceId)
-	response, _ := httpGet(url, "")
-	json.Unmarshal([]byte(response), &workspaceAccess)
-	return workspaceAccess
+	err := c.getJSON(ctx, url, &workspaceAccess)
+	return workspaceAccess, err
 }
 
 // Retrieves information about a specific document
-func GetDoc(docId string) Doc {
+func (c *Client) GetDoc(ctx context.Context, docId string) (Doc, error) {
 	doc := Doc{}
 	url := "docs/" + docId
-	response, _ := httpGet(url, "")
-	json.Unmarshal([]byte(response), &doc)
-	return doc
+	err := c.getJSON(ctx, url, &doc)
+	return doc, err
 }
 
 // Retrieves the list of tables contained in a document
-func GetDocTables(docId string) Tables {
+func (c *Client) GetDocTables(ctx context.Context, docId string) (Tables, error) {
 	tables := Tables{}
 	url := "docs/" + docId + "/tables"
-	response, _ := httpGet(url, "")
-	json.Unmarshal([]byte(response), &tables)
-
-	return tables
+	err := c.getJSON(ctx, url, &tables)
+	return tables, err
 }
 
 // Retrieves a list of table columns
-func GetTableColumns(docId string, tableId string) TableColumns {
+func (c *Client) GetTableColumns(ctx context.Context, docId string, tableId string) (TableColumns, error) {
 	columns := TableColumns{}
 	url := "docs/" + docId + "/tables/" + tableId + "/columns"
-	response, _ := httpGet(url, "")
-	json.Unmarshal([]byte(response), &columns)
-
-	return columns
+	err := c.getJSON(ctx, url, &columns)
+	return columns, err
 }
 
 // Retrieves records from a table
-func GetTableRows(docId string, tableId string) TableRows {
+func (c *Client) GetTableRows(ctx context.Context, docId string, tableId string) (TableRows, error) {
 	rows := TableRows{}
 	url := "docs/" + docId + "/tables/" + tableId + "/data"
-	response, _ := httpGet(url, "")
-	json.Unmarshal([]byte(response), &rows)
-
-	return rows
+	err := c.getJSON(ctx, url, &rows)
+	return rows, err
 }
 
 // Returns the list of users with access to the document
-func GetDocAccess(docId string) EntityAccess {
+func (c *Client) GetDocAccess(ctx context.Context, docId string) (EntityAccess, error) {
 	var lstUsers EntityAccess
 	url := fmt.Sprintf("docs/%s/access", docId)
-	response, _ := httpGet(url, "")
-	json.Unmarshal([]byte(response), &lstUsers)
-	return lstUsers
+	err := c.getJSON(ctx, url, &lstUsers)
+	return lstUsers, err
 }
 
 // Get user information from id
-func GetUser(userId int) ScimUser {
+func (c *Client) GetUser(ctx context.Context, userId int) (ScimUser, error) {
 	user := ScimUser{}
 	url := fmt.Sprintf("scim/v2/Users/%d", userId)
-	response, status := httpGet(url, "")
-	if status == http.StatusOK {
-		json.Unmarshal([]byte(response), &user)
-	}
-	return user
+	err := c.getJSON(ctx, url, &user)
+	return user, err
 }
 
 // Get user list
-func GetUsers() []ScimUser {
-	users := []ScimUser{}
-	url := fmt.Sprintf("scim/v2/Users")
-	response, status := httpGet(url, "")
-	if status == http.StatusOK {
-		var result struct {
-			Resources []ScimUser `json:"Resources"`
-		}
-		json.Unmarshal([]byte(response), &result)
-		users = result.Resources
-	} else {
-		fmt.Printf("Unable to retrieve users: %s\n", response)
+func (c *Client) GetUsers(ctx context.Context) ([]ScimUser, error) {
+	var result struct {
+		Resources []ScimUser `json:"Resources"`
 	}
-	return users
+	err := c.getJSON(ctx, "scim/v2/Users", &result)
+	return result.Resources, err
 }
 
 // Purge a document's history, to retain only the last modifications
-func PurgeDoc(docId string, nbHisto int) {
-	url := "docs/" + docId + "/states/remove"
-	data := fmt.Sprintf(`{"keep": "%d"}`, nbHisto)
-	_, status := httpPost(url, data)
-	if status == http.StatusOK {
-		fmt.Printf("History cleared (%d last states) ✅\n", nbHisto)
-	}
+func (c *Client) PurgeDoc(ctx context.Context, docId string, nbHisto int) error {
+	return c.sendJSON(ctx, "POST", "docs/"+docId+"/states/remove", map[string]any{"keep": nbHisto}, nil)
 }
 
 // Import a list of user & role into a workspace
-// Search workspace by name in org
-func ImportUsers(orgId int, workspaceName string, users []UserRole) {
-	lstWorkspaces := GetOrgWorkspaces(orgId)
-	idWorkspace := 0
-	for _, ws := range lstWorkspaces {
-		if ws.Name == workspaceName {
-			idWorkspace = ws.Id
-		}
-	}
-
-	if idWorkspace == 0 {
-		idWorkspace = CreateWorkspace(orgId, workspaceName)
-		fmt.Printf("Workspace '%s' created with id %d\n", workspaceName, idWorkspace)
+// Search workspace by name in org, and create it if it is missing
+// Returns the workspace id and whether it was created
+func (c *Client) ImportUsers(ctx context.Context, orgId int, workspaceName string, users []UserRole) (int, bool, error) {
+	idWorkspace, created, err := c.FindOrCreateWorkspace(ctx, orgId, workspaceName)
+	if err != nil {
+		return 0, false, err
 	}
-	if idWorkspace == 0 {
-		fmt.Printf("Unable to create workspace %s\n", workspaceName)
-	} else {
-		url := fmt.Sprintf("workspaces/%d/access", idWorkspace)
-
-		roleLine := []string{}
-		for _, role := range users {
-			roleLine = append(roleLine, fmt.Sprintf(`"%s": "%s"`, role.Email, role.Role))
-		}
-		patch := fmt.Sprintf(`{	"delta": { "users": {%s}}}`, strings.Join(roleLine, ","))
-
-		body, status := httpPatch(url, patch)
 
-		var result string
-		if status == http.StatusOK {
-			result = "✅"
-		} else {
-			result = fmt.Sprintf("❗️ (%s)", body)
-		}
-		fmt.Printf("Import %d users in workspace n°%d\t : %s\n", len(users), idWorkspace, result)
+	delta := AccessDelta{Users: map[string]*string{}}
+	for _, user := range users {
+		role := user.Role
+		delta.Users[user.Email] = &role
 	}
-
+	err = c.UpdateWorkspaceAccess(ctx, idWorkspace, delta)
+	return idWorkspace, created, err
 }
 
 // Create a workspace in an organization
-func CreateWorkspace(orgId int, workspaceName string) int {
-	url := fmt.Sprintf("orgs/%d/workspaces", orgId)
-	data := fmt.Sprintf(`{"name":"%s"}`, workspaceName)
-	body, status := httpPost(url, data)
+// Returns the id of the new workspace
+func (c *Client) CreateWorkspace(ctx context.Context, orgId int, workspaceName string) (int, error) {
 	idWorkspace := 0
-	if status == http.StatusOK {
-		id, err := strconv.Atoi(body)
-		if err == nil {
-			idWorkspace = id
-		}
+	url := fmt.Sprintf("orgs/%d/workspaces", orgId)
+	if err := c.sendJSON(ctx, "POST", url, map[string]any{"name": workspaceName}, &idWorkspace); err != nil {
+		return 0, err
 	}
-	return idWorkspace
+	return idWorkspace, nil
 }
 
-// Export doc in Grist format (Sqlite) in fileName file
-func ExportDocGrist(docId string, fileName string) {
-	url := fmt.Sprintf("docs/%s/download", docId)
-	export, returnCode := httpGet(url, "")
-	if returnCode == http.StatusOK {
-		f, e := os.Create(fileName)
-		if e != nil {
-			panic(e)
+// Search a workspace by name in an organization
+// Returns the id of the workspace, or 0 if there is no workspace with this name
+func (c *Client) FindWorkspace(ctx context.Context, orgId int, workspaceName string) (int, error) {
+	lstWorkspaces, err := c.GetOrgWorkspaces(ctx, orgId)
+	if err != nil {
+		return 0, err
+	}
+	for _, ws := range lstWorkspaces {
+		if ws.Name == workspaceName {
+			return ws.Id, nil
 		}
-		defer f.Close()
-		fmt.Fprintln(f, export)
 	}
+	return 0, nil
 }
 
-// Export doc in Excel format (XLSX) in fileName file
-func ExportDocExcel(docId string, fileName string) {
-	url := fmt.Sprintf("docs/%s/download/xlsx", docId)
-	export, returnCode := httpGet(url, "")
-	if returnCode == http.StatusOK {
-		f, e := os.Create(fileName)
-		if e != nil {
-			panic(e)
+// Search a workspace by name in an organization, and create it if it is missing
+// Returns the workspace id and whether it was created
+// A workspace with this name in the trash is reported instead of being duplicated
+func (c *Client) FindOrCreateWorkspace(ctx context.Context, orgId int, workspaceName string) (int, bool, error) {
+	idWorkspace, err := c.FindWorkspace(ctx, orgId, workspaceName)
+	if err != nil || idWorkspace != 0 {
+		return idWorkspace, false, err
+	}
+	trash, err := c.GetTrash(ctx, orgId)
+	if err != nil {
+		return 0, false, err
+	}
+	for _, ws := range trash {
+		if ws.Name == workspaceName && ws.RemovedAt != "" {
+			return 0, false, fmt.Errorf("workspace '%s' (%d) is in the trash, use 'restore workspace %d'", ws.Name, ws.Id, ws.Id)
 		}
-		defer f.Close()
-		fmt.Fprintln(f, export)
 	}
+	idWorkspace, err = c.CreateWorkspace(ctx, orgId, workspaceName)
+	return idWorkspace, err == nil, err
+}
+
+// Rename a workspace
+func (c *Client) RenameWorkspace(ctx context.Context, workspaceId int, workspaceName string) error {
+	return c.sendJSON(ctx, "PATCH", fmt.Sprintf("workspaces/%d", workspaceId), map[string]any{"name": workspaceName}, nil)
 }
 
-// Returns table content as Dataframe
-func GetTableContent(docId string, tableName string) {
+// Returns table content as CSV
+func (c *Client) GetTableContent(ctx context.Context, docId string, tableName string) (string, error) {
 	url := fmt.Sprintf("docs/%s/download/csv?tableId=%s", docId, tableName)
-	csvFile, _ := httpGet(url, "")
-	fmt.Println(csvFile)
+	csvFile, err := c.httpGet(ctx, url, "")
+	return string(csvFile), err
 }
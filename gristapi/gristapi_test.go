@@ -5,12 +5,775 @@
 package gristapi
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
+func TestClientOptions(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/orgs" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("Unexpected authorization header %s", auth)
+		}
+		if ua := r.Header.Get("User-Agent"); ua != "test-agent" {
+			t.Errorf("Unexpected user agent %s", ua)
+		}
+		fmt.Fprint(w, `[{"id": 1, "name": "Personal"}, {"id": 2, "name": "ems"}]`)
+	}))
+	defer server.Close()
+
+	httpClient := &http.Client{}
+	client := NewClient("http://invalid.example/", "secret",
+		WithBaseURL(server.URL+"/"),
+		WithHTTPClient(httpClient),
+		WithUserAgent("test-agent"),
+		WithTimeout(5*time.Second))
+
+	if client.BaseURL() != server.URL {
+		t.Errorf("Base URL should be %s, not %s", server.URL, client.BaseURL())
+	}
+	if httpClient.Timeout != 0 {
+		t.Error("The HTTP client passed as option should not be modified")
+	}
+	orgs, err := client.GetOrgs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orgs) != 2 || orgs[1].Name != "ems" {
+		t.Errorf("Unexpected organizations %v", orgs)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error": "document not found"}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "secret")
+	_, err := client.GetDoc(ctx, "unknown")
+	if !IsNotFound(err) {
+		t.Fatalf("Error should be a 'not found' error : %v", err)
+	}
+	if IsForbidden(err) || IsUnauthorized(err) {
+		t.Errorf("Error should only be a 'not found' error : %v", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Error should be an APIError : %v", err)
+	}
+	if apiErr.Endpoint != "docs/unknown" || apiErr.Message != "document not found" {
+		t.Errorf("Unexpected error content : %+v", apiErr)
+	}
+
+	_, err = NewClient("http://127.0.0.1:1", "secret", WithRetryPolicy(NoRetry)).GetOrgs(ctx)
+	if err == nil || StatusCode(err) != 0 {
+		t.Errorf("A connection error should not have a status code : %v", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	nbCalls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nbCalls++
+		if nbCalls < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	}))
+	defer server.Close()
+
+	policy := RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	client := NewClient(server.URL, "secret", WithRetryPolicy(policy))
+
+	// GET requests are retried
+	if _, err := client.GetOrgs(ctx); err != nil {
+		t.Errorf("Request should succeed after retries : %v", err)
+	}
+	if nbCalls != 3 {
+		t.Errorf("Request should have been sent 3 times, not %d", nbCalls)
+	}
+
+	// POST requests are not retried when the server answers
+	nbCalls = 0
+	if _, err := client.CreateWorkspace(ctx, 1, "test"); StatusCode(err) != http.StatusTooManyRequests {
+		t.Errorf("Request should fail with status 429 : %v", err)
+	}
+	if nbCalls != 1 {
+		t.Errorf("POST request should have been sent once, not %d times", nbCalls)
+	}
+
+	// Number of attempts is limited
+	nbCalls = -10
+	if _, err := client.GetOrgs(ctx); StatusCode(err) != http.StatusTooManyRequests {
+		t.Errorf("Request should fail after 3 attempts : %v", err)
+	}
+	if nbCalls != -7 {
+		t.Errorf("Request should have been sent 3 times, not %d", nbCalls+10)
+	}
+}
+
+func TestContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server never answers before the request is canceled
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "secret")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.GetOrgs(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Request should fail with the context deadline : %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("Request should not be retried once the context is done")
+	}
+}
+
+func TestExportDoc(t *testing.T) {
+	ctx := context.Background()
+	content := "SQLite format 3\x00 content of the document"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/docs/good/download":
+			w.Header().Set("Content-Type", "application/x-sqlite3")
+			fmt.Fprint(w, content)
+		case "/api/docs/excel/download/xlsx":
+			fmt.Fprint(w, content)
+		case "/api/docs/truncated/download":
+			w.Header().Set("Content-Length", "1000")
+			fmt.Fprint(w, content)
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "secret")
+
+	fileName := filepath.Join(t.TempDir(), "doc.grist")
+	size, err := SaveToFile(fileName, func(w io.Writer) (int64, error) {
+		return client.ExportDocGrist(ctx, "good", w)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(saved) != content || size != int64(len(content)) {
+		t.Errorf("Saved file does not match the download (%d bytes : %q)", size, saved)
+	}
+
+	var buf bytes.Buffer
+	if _, err := client.ExportDocExcel(ctx, "excel", &buf); err == nil {
+		t.Error("A Grist file should not be accepted as an Excel export")
+	}
+
+	fileName = filepath.Join(t.TempDir(), "truncated.grist")
+	_, err = SaveToFile(fileName, func(w io.Writer) (int64, error) {
+		return client.ExportDocGrist(ctx, "truncated", w)
+	})
+	if err == nil {
+		t.Error("A truncated download should fail")
+	}
+	if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+		t.Error("A truncated download should not be saved")
+	}
+}
+
+func TestRecords(t *testing.T) {
+	ctx := context.Background()
+	requests := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests[r.Method+" "+r.URL.Path] = string(body)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/docs/doc/tables/People/records":
+			if r.URL.Query().Get("filter") != `{"Name":["Alice"]}` || r.URL.Query().Get("sort") != "-Age" || r.URL.Query().Get("limit") != "1" {
+				t.Errorf("Unexpected query %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `{"records": [{"id": 1, "fields": {"Name": "Alice", "Age": 12345678901234}}]}`)
+		case "POST /api/docs/doc/tables/People/records":
+			fmt.Fprint(w, `{"records": [{"id": 2}, {"id": 3}]}`)
+		case "PATCH /api/docs/doc/tables/People/records", "PUT /api/docs/doc/tables/People/records", "POST /api/docs/doc/tables/People/data/delete":
+			fmt.Fprint(w, `null`)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "secret")
+
+	records, err := client.GetRecords(ctx, "doc", "People", RecordsQuery{Filter: map[string][]any{"Name": {"Alice"}}, Sort: "-Age", Limit: 1})
+	if err != nil || len(records) != 1 || records[0].Id != 1 || fmt.Sprint(records[0].Fields["Age"]) != "12345678901234" {
+		t.Errorf("Unexpected records %+v (%v)", records, err)
+	}
+
+	ids, err := client.AddRecords(ctx, "doc", "People", []Record{{Id: 9, Fields: map[string]any{"Name": "Bob"}}, {Fields: map[string]any{"Name": "Carol"}}})
+	if err != nil || len(ids) != 2 || ids[0] != 2 {
+		t.Errorf("Unexpected ids of the new records %v (%v)", ids, err)
+	}
+	if body := requests["POST /api/docs/doc/tables/People/records"]; body != `{"records":[{"fields":{"Name":"Bob"}},{"fields":{"Name":"Carol"}}]}` {
+		t.Errorf("Unexpected body of the new records %s", body)
+	}
+
+	if err := client.UpdateRecords(ctx, "doc", "People", []Record{{Fields: map[string]any{"Name": "Bob"}}}); err == nil {
+		t.Error("Updating a record without id should fail")
+	}
+	if err := client.UpdateRecords(ctx, "doc", "People", []Record{{Id: 2, Fields: map[string]any{"Age": 30}}}); err != nil {
+		t.Errorf("Error updating records : %s", err)
+	}
+	if body := requests["PATCH /api/docs/doc/tables/People/records"]; body != `{"records":[{"id":2,"fields":{"Age":30}}]}` {
+		t.Errorf("Unexpected body of the updated records %s", body)
+	}
+
+	if err := client.UpsertRecords(ctx, "doc", "People", []UpsertRecord{{Require: map[string]any{"Name": "Bob"}, Fields: map[string]any{"Age": 31}}}); err != nil {
+		t.Errorf("Error upserting records : %s", err)
+	}
+	if body := requests["PUT /api/docs/doc/tables/People/records"]; body != `{"records":[{"require":{"Name":"Bob"},"fields":{"Age":31}}]}` {
+		t.Errorf("Unexpected body of the upserted records %s", body)
+	}
+
+	if err := client.DeleteRecords(ctx, "doc", "People", []int{2, 3}); err != nil {
+		t.Errorf("Error deleting records : %s", err)
+	}
+	if body := requests["POST /api/docs/doc/tables/People/data/delete"]; body != `[2,3]` {
+		t.Errorf("Unexpected body of the deleted records %s", body)
+	}
+}
+
+func TestQuerySQL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/docs/doc/sql" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		query := struct {
+			Sql     string `json:"sql"`
+			Args    []any  `json:"args"`
+			Timeout int    `json:"timeout"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&query)
+		if query.Sql != "SELECT Name, Age FROM People WHERE Age > ?" || len(query.Args) != 1 || query.Args[0] != 18.0 {
+			t.Errorf("Unexpected query %+v", query)
+		}
+		if query.Timeout <= 0 || query.Timeout > 10000 {
+			t.Errorf("The timeout of the query should be set from the context : %d", query.Timeout)
+		}
+		fmt.Fprint(w, `{"statement": "...", "records": [{"fields": {"Name": "Bob", "Age": 42}}, {"fields": {"Name": "Carol", "Age": 37}}]}`)
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "secret")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	result, err := client.QuerySQL(ctx, "doc", "SELECT Name, Age FROM People WHERE Age > ?", []any{18})
+	if err != nil {
+		t.Fatalf("Error running the query : %s", err)
+	}
+	if fmt.Sprint(result.Columns) != "[Name Age]" || len(result.Rows) != 2 || fmt.Sprint(result.Rows[1]) != "[Carol 37]" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestTables(t *testing.T) {
+	ctx := context.Background()
+	requests := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests[r.Method+" "+r.URL.Path] = string(body)
+		switch r.Method + " " + r.URL.Path {
+		case "POST /api/docs/doc/tables":
+			fmt.Fprint(w, `{"tables": [{"id": "People"}]}`)
+		case "POST /api/docs/doc/tables/People/columns":
+			fmt.Fprint(w, `{"columns": [{"id": "Age2"}]}`)
+		case "PATCH /api/docs/doc/tables/People/columns", "DELETE /api/docs/doc/tables/People/columns/Age", "POST /api/docs/doc/apply":
+			fmt.Fprint(w, `null`)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "secret")
+
+	ids, err := client.CreateTables(ctx, "doc", []TableSchema{{Id: "People", Columns: []Column{{Id: "Name", Fields: ColumnFields{Type: "Text"}}}}})
+	if err != nil || fmt.Sprint(ids) != "[People]" {
+		t.Errorf("Unexpected ids of the new tables %v (%v)", ids, err)
+	}
+	if body := requests["POST /api/docs/doc/tables"]; body != `{"tables":[{"id":"People","columns":[{"id":"Name","fields":{"type":"Text"}}]}]}` {
+		t.Errorf("Unexpected body of the new tables %s", body)
+	}
+
+	ids, err = client.AddColumns(ctx, "doc", "People", []Column{{Id: "Age", Fields: ColumnFields{Type: "Int"}}})
+	if err != nil || fmt.Sprint(ids) != "[Age2]" {
+		t.Errorf("Unexpected ids of the new columns %v (%v)", ids, err)
+	}
+
+	isFormula := false
+	if err := client.UpdateColumns(ctx, "doc", "People", []Column{{Id: "Age", Fields: ColumnFields{Label: "Age", IsFormula: &isFormula}}}); err != nil {
+		t.Errorf("Error modifying columns : %s", err)
+	}
+	if body := requests["PATCH /api/docs/doc/tables/People/columns"]; body != `{"columns":[{"id":"Age","fields":{"label":"Age","isFormula":false}}]}` {
+		t.Errorf("Only the given properties should be modified : %s", body)
+	}
+	if err := client.UpdateColumns(ctx, "doc", "People", []Column{{Id: "Age", Fields: ColumnFields{Label: "Age", Cleared: []string{"formula"}}}}); err != nil {
+		t.Errorf("Error modifying columns : %s", err)
+	}
+	if body := requests["PATCH /api/docs/doc/tables/People/columns"]; body != `{"columns":[{"id":"Age","fields":{"formula":"","label":"Age"}}]}` {
+		t.Errorf("The cleared properties should be sent empty : %s", body)
+	}
+
+	if err := client.DeleteColumn(ctx, "doc", "People", "Age"); err != nil {
+		t.Errorf("Error deleting a column : %s", err)
+	}
+	if err := client.DeleteTable(ctx, "doc", "People"); err != nil {
+		t.Errorf("Error deleting a table : %s", err)
+	}
+	if body := requests["POST /api/docs/doc/apply"]; body != `[["RemoveTable","People"]]` {
+		t.Errorf("Unexpected action removing a table %s", body)
+	}
+}
+
+func TestDocs(t *testing.T) {
+	ctx := context.Background()
+	requests := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests[r.Method+" "+r.URL.Path] = string(body)
+		switch r.Method + " " + r.URL.Path {
+		case "POST /api/workspaces/12/docs":
+			fmt.Fprint(w, `"newDoc"`)
+		case "POST /api/docs/doc/copy":
+			fmt.Fprint(w, `"copyDoc"`)
+		case "PATCH /api/docs/doc", "PATCH /api/docs/doc/move", "POST /api/docs/doc/replace":
+			fmt.Fprint(w, `null`)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "secret")
+
+	docId, err := client.CreateDoc(ctx, 12, `My "doc"`)
+	if err != nil || docId != "newDoc" {
+		t.Errorf("Unexpected id of the new document %s (%v)", docId, err)
+	}
+	if body := requests["POST /api/workspaces/12/docs"]; body != `{"name":"My \"doc\""}` {
+		t.Errorf("The name of the document should be escaped : %s", body)
+	}
+
+	if err := client.RenameDoc(ctx, "doc", "Renamed"); err != nil || requests["PATCH /api/docs/doc"] != `{"name":"Renamed"}` {
+		t.Errorf("Unexpected renaming %s (%v)", requests["PATCH /api/docs/doc"], err)
+	}
+
+	docId, err = client.CopyDoc(ctx, "doc", 13, "Copy", true)
+	if err != nil || docId != "copyDoc" {
+		t.Errorf("Unexpected id of the copy %s (%v)", docId, err)
+	}
+	if body := requests["POST /api/docs/doc/copy"]; body != `{"asTemplate":true,"documentName":"Copy","workspaceId":13}` {
+		t.Errorf("Unexpected copy request %s", body)
+	}
+
+	if err := client.MoveDoc(ctx, "doc", 14); err != nil || requests["PATCH /api/docs/doc/move"] != `{"workspace":14}` {
+		t.Errorf("Unexpected move request %s (%v)", requests["PATCH /api/docs/doc/move"], err)
+	}
+
+	if err := client.ReplaceDoc(ctx, "doc", "source"); err != nil || requests["POST /api/docs/doc/replace"] != `{"sourceDocId":"source"}` {
+		t.Errorf("Unexpected replace request %s (%v)", requests["POST /api/docs/doc/replace"], err)
+	}
+}
+
+// Transport calling a function for each request
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestImportDoc(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/workspaces/12/import" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		// The body is streamed
+		if r.ContentLength != -1 {
+			t.Errorf("Unexpected content length %d", r.ContentLength)
+		}
+		file, header, err := r.FormFile("upload")
+		if err != nil {
+			t.Errorf("The file should be uploaded in the upload field : %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		content, _ := io.ReadAll(file)
+		if header.Filename != "Budget.csv" || string(content) != "a,b\n1,2\n" {
+			t.Errorf("Unexpected upload %s : %q", header.Filename, content)
+		}
+		fmt.Fprint(w, `{"id": "newDoc", "title": "Budget"}`)
+	}))
+	defer server.Close()
+
+	// The first attempt fails after reading a part of the body, as if the connection could not be established
+	attempts := 0
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		if attempts == 1 {
+			r.Body.Read(make([]byte, 10))
+			r.Body.Close()
+			return nil, &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+		}
+		return http.DefaultTransport.RoundTrip(r)
+	})
+	policy := RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	client := NewClient(server.URL, "secret", WithHTTPClient(&http.Client{Transport: transport}), WithRetryPolicy(policy))
+
+	docId, err := client.ImportDoc(ctx, 12, "/tmp/data/budget_2025.csv", strings.NewReader("a,b\n1,2\n"), "Budget")
+	if err != nil || docId != "newDoc" || attempts != 2 {
+		t.Errorf("Unexpected id of the imported document %s after %d attempts (%v)", docId, attempts, err)
+	}
+
+	// A content which cannot be read again is not sent again
+	attempts = 0
+	if _, err := client.ImportDoc(ctx, 12, "budget.csv", io.MultiReader(strings.NewReader("a,b\n")), ""); err == nil || attempts != 1 {
+		t.Errorf("The upload should not be retried (%d attempts, %v)", attempts, err)
+	}
+	if _, err := client.ImportDoc(ctx, 12, "notes.txt", strings.NewReader(""), ""); err == nil {
+		t.Error("A text file should not be imported")
+	}
+}
+
+func TestDocHistory(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/docs/doc1/states":
+			fmt.Fprint(w, `{"states": [{"n": 12, "h": "abc"}, {"n": 11, "h": "def"}]}`)
+		case "/api/docs/doc1/snapshots":
+			fmt.Fprint(w, `{"snapshots": [{"snapshotId": "s1", "lastModified": "2024-10-01T10:00:00Z", "docId": "doc1~v=s1", "metadata": {"label": "before import", "h": "abc"}}]}`)
+		case "/api/docs/doc1~v=s1/download":
+			fmt.Fprint(w, "SQLite format 3\x00content")
+		case "/api/docs/doc1/states/remove":
+			if body, _ := io.ReadAll(r.Body); string(body) != `{"keep":5}` {
+				t.Errorf("Unexpected purge request %s", body)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "secret")
+
+	states, err := client.GetDocStates(ctx, "doc1")
+	if err != nil || len(states) != 2 || states[0].N != 12 || states[0].H != "abc" {
+		t.Errorf("Unexpected states %v (%v)", states, err)
+	}
+	if _, err := client.GetDocStates(ctx, "unknown"); !IsNotFound(err) {
+		t.Errorf("A missing document should return a not found error : %v", err)
+	}
+
+	snapshots, err := client.GetDocSnapshots(ctx, "doc1")
+	if err != nil || len(snapshots) != 1 || snapshots[0].DocId != "doc1~v=s1" || snapshots[0].Metadata.Label != "before import" {
+		t.Errorf("Unexpected snapshots %v (%v)", snapshots, err)
+	}
+	if size, err := client.GetDocSize(ctx, snapshots[0].DocId); err != nil || size != 23 {
+		t.Errorf("Unexpected size of the snapshot %d (%v)", size, err)
+	}
+	if err := client.PurgeDoc(ctx, "doc1", 5); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateAccess(t *testing.T) {
+	ctx := context.Background()
+	requests := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests[r.Method+" "+r.URL.Path] = string(body)
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "secret")
+
+	editors, none := "editors", ""
+	delta := AccessDelta{MaxInheritedRole: &none, Users: map[string]*string{"a@example.com": &editors, "b@example.com": nil}}
+	if err := client.UpdateWorkspaceAccess(ctx, 12, delta); err != nil {
+		t.Fatal(err)
+	}
+	if body := requests["PATCH /api/workspaces/12/access"]; body != `{"delta":{"maxInheritedRole":null,"users":{"a@example.com":"editors","b@example.com":null}}}` {
+		t.Errorf("Unexpected access delta %s", body)
+	}
+	if err := client.UpdateDocAccess(ctx, "doc", AccessDelta{MaxInheritedRole: &editors}); err != nil {
+		t.Fatal(err)
+	}
+	if body := requests["PATCH /api/docs/doc/access"]; body != `{"delta":{"maxInheritedRole":"editors"}}` {
+		t.Errorf("Unexpected access delta %s", body)
+	}
+	if err := client.UpdateOrgAccess(ctx, 3, AccessDelta{Users: map[string]*string{"a@example.com": &editors}}); err != nil {
+		t.Fatal(err)
+	}
+	if body := requests["PATCH /api/orgs/3/access"]; body != `{"delta":{"users":{"a@example.com":"editors"}}}` {
+		t.Errorf("Unexpected access delta %s", body)
+	}
+}
+
+func TestWorkspaces(t *testing.T) {
+	ctx := context.Background()
+	requests := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests[r.Method+" "+r.URL.Path] = string(body)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/orgs/2/workspaces":
+			if r.URL.Query().Get("showRemoved") == "1" {
+				fmt.Fprint(w, `[{"id": 12, "name": "Trashed", "removedAt": "2024-05-01T10:00:00Z"}]`)
+			} else {
+				fmt.Fprint(w, `[{"id": 10, "name": "Existing"}]`)
+			}
+		case "POST /api/orgs/2/workspaces":
+			fmt.Fprint(w, `11`)
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "secret")
+
+	workspaceId, created, err := client.FindOrCreateWorkspace(ctx, 2, "Existing")
+	if err != nil || workspaceId != 10 || created {
+		t.Errorf("The existing workspace should be found : %d %v (%v)", workspaceId, created, err)
+	}
+	workspaceId, created, err = client.FindOrCreateWorkspace(ctx, 2, `My "workspace"`)
+	if err != nil || workspaceId != 11 || !created {
+		t.Errorf("The workspace should be created : %d %v (%v)", workspaceId, created, err)
+	}
+	if body := requests["POST /api/orgs/2/workspaces"]; body != `{"name":"My \"workspace\""}` {
+		t.Errorf("The name of the workspace should be escaped : %s", body)
+	}
+	delete(requests, "POST /api/orgs/2/workspaces")
+	workspaceId, created, err = client.FindOrCreateWorkspace(ctx, 2, "Trashed")
+	if err == nil || !strings.Contains(err.Error(), "restore workspace 12") || created {
+		t.Errorf("The workspace in the trash should be reported : %d %v (%v)", workspaceId, created, err)
+	}
+	if _, found := requests["POST /api/orgs/2/workspaces"]; found {
+		t.Errorf("No workspace should be created when it is in the trash")
+	}
+
+	if err := client.RenameWorkspace(ctx, 10, "Renamed"); err != nil || requests["PATCH /api/workspaces/10"] != `{"name":"Renamed"}` {
+		t.Errorf("Unexpected renaming %s (%v)", requests["PATCH /api/workspaces/10"], err)
+	}
+
+	users := []UserRole{{Email: `o'brien@example.com`, Role: "editors"}}
+	if _, _, err := client.ImportUsers(ctx, 2, "Existing", users); err != nil {
+		t.Fatal(err)
+	}
+	if body := requests["PATCH /api/workspaces/10/access"]; body != `{"delta":{"users":{"o'brien@example.com":"editors"}}}` {
+		t.Errorf("Unexpected access request %s", body)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	ctx := context.Background()
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		if r.URL.Path == "/api/orgs/2/workspaces" {
+			fmt.Fprint(w, `[
+				{"id": 1, "name": "Kept", "docs": [{"id": "a", "name": "A"}, {"id": "b", "name": "B", "removedAt": "2024-10-01T10:00:00Z"}]},
+				{"id": 2, "name": "Removed", "removedAt": "2024-10-02T10:00:00Z", "docs": [{"id": "c", "name": "C"}]},
+				{"id": 3, "name": "Clean", "docs": [{"id": "d", "name": "D"}]}
+			]`)
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "secret")
+
+	client.RemoveDoc(ctx, "a")
+	client.UnremoveDoc(ctx, "a")
+	client.RemoveWorkspace(ctx, 1)
+	client.UnremoveWorkspace(ctx, 1)
+	trash, err := client.GetTrash(ctx, 2)
+	expected := []string{
+		"POST /api/docs/a/remove", "POST /api/docs/a/unremove",
+		"POST /api/workspaces/1/remove", "POST /api/workspaces/1/unremove",
+		"GET /api/orgs/2/workspaces?showRemoved=1",
+	}
+	if strings.Join(requests, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected requests %v", requests)
+	}
+	if err != nil || len(trash) != 2 || len(trash[0].Docs) != 1 || trash[0].Docs[0].Id != "b" || trash[1].RemovedAt == "" || len(trash[1].Docs) != 1 {
+		t.Errorf("Unexpected trash %v (%v)", trash, err)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GRIST_URL", "")
+	t.Setenv("GRIST_TOKEN", "")
+	t.Setenv("GRIST_PROFILE", "")
+	configFile := ConfigPath()
+
+	// Previous format, without profile
+	os.WriteFile(configFile, []byte("GRIST_URL=\"https://grist.example.com\"\nGRIST_TOKEN=\"secret\"\n"), 0600)
+	profile, err := LoadProfile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Name != DefaultProfile || profile.URL != "https://grist.example.com" || profile.Token != "secret" {
+		t.Errorf("Unexpected default profile %+v", profile)
+	}
+
+	// Adding profiles
+	config, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.SetProfile(Profile{Name: "staging", URL: "https://staging.example.com", Token: "tok\"en"})
+	config.SetProfile(Profile{Name: "prod", URL: "https://prod.example.com", Token: "prod"})
+	config.Current = "prod"
+	if err := config.Save(configFile); err != nil {
+		t.Fatal(err)
+	}
+	if profile, _ := LoadProfile(""); profile.Name != "prod" {
+		t.Errorf("Current profile should be prod, not %s", profile.Name)
+	}
+	t.Setenv("GRIST_PROFILE", "staging")
+	if profile, _ := LoadProfile(""); profile.Token != "tok\"en" {
+		t.Errorf("GRIST_PROFILE should select the staging profile : %+v", profile)
+	}
+	if profile, _ := LoadProfile(DefaultProfile); profile.URL != "https://grist.example.com" {
+		t.Errorf("The profile passed as parameter should be used : %+v", profile)
+	}
+	if _, err := LoadProfile("unknown"); err == nil {
+		t.Error("An unknown profile should not be found")
+	}
+
+	// Removing the current profile
+	config.RemoveProfile("prod")
+	config.Save(configFile)
+	config, _ = LoadConfig(configFile)
+	if len(config.Profiles) != 2 || config.CurrentName() != DefaultProfile {
+		t.Errorf("Unexpected configuration after removing a profile : %+v", config)
+	}
+}
+
+// Secret store keeping the tokens in memory
+type memoryStore map[string]string
+
+func (s memoryStore) Get(profile string) (string, error) {
+	token, found := s[profile]
+	if !found {
+		return "", errors.New("not found")
+	}
+	return token, nil
+}
+
+func (s memoryStore) Set(profile string, token string) error {
+	s[profile] = token
+	return nil
+}
+
+func (s memoryStore) Delete(profile string) error {
+	delete(s, profile)
+	return nil
+}
+
+func TestToken(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GRIST_PROFILE", "")
+	t.Setenv("GRIST_URL", "")
+	t.Setenv("GRIST_TOKEN", "")
+	store := memoryStore{}
+	RegisterSecretStore("memory", store)
+	store.Set("stored", "secret")
+
+	configFile := ConfigPath()
+	// Permissions of a file written by a previous version
+	os.WriteFile(configFile, []byte(""), 0644)
+	config := &Config{}
+	config.SetProfile(Profile{Name: "file", URL: "https://grist.example.com", Token: "plain"})
+	config.SetProfile(Profile{Name: "command", URL: "https://grist.example.com", TokenCommand: "echo ' from-command '; echo other"})
+	config.SetProfile(Profile{Name: "stored", URL: "https://grist.example.com", TokenStore: "memory"})
+	config.SetProfile(Profile{Name: "missing", URL: "https://grist.example.com", TokenStore: "memory"})
+	config.SetProfile(Profile{Name: "failing", URL: "https://grist.example.com", TokenCommand: "exit 1"})
+	config.SetProfile(Profile{Name: "fallback", URL: "https://grist.example.com", TokenStore: "memory", Token: "plain-fallback"})
+	if err := config.Save(configFile); err != nil {
+		t.Fatalf("Error saving the configuration : %s", err)
+	}
+	if readable, _ := IsConfigReadableByOthers(configFile); readable && runtime.GOOS != "windows" {
+		t.Error("The configuration file should only be readable by its owner")
+	}
+	content, _ := os.ReadFile(configFile)
+	if strings.Contains(string(content), "secret") || !strings.Contains(string(content), `GRIST_TOKEN_STORE="memory"`) {
+		t.Errorf("The stored token should not be written in the file : %s", content)
+	}
+
+	for name, expected := range map[string]string{"file": "plain", "command": "from-command", "stored": "secret"} {
+		profile, err := LoadProfile(name)
+		if err != nil || profile.Token != expected {
+			t.Errorf("Unexpected token for profile %s : %s (%v)", name, profile.Token, err)
+		}
+	}
+	// The token of the file is used when the store fails, and the caller is told about it
+	if profile, err := LoadProfile("fallback"); profile.Token != "plain-fallback" || !IsTokenFallback(err) || !strings.Contains(err.Error(), "memory") {
+		t.Errorf("Unexpected fallback token : %s (%v)", profile.Token, err)
+	}
+	for _, name := range []string{"missing", "failing"} {
+		if _, err := LoadProfile(name); err == nil || IsTokenFallback(err) {
+			t.Errorf("Getting the token of profile %s should fail (%v)", name, err)
+		}
+	}
+	if _, err := (Profile{Name: "x", TokenStore: "unknown"}).GetToken(); err == nil {
+		t.Error("An unknown secret store should be an error")
+	}
+
+	// The configuration keeps the way the token is obtained
+	config, _ = LoadConfig(configFile)
+	if profile, _ := config.Profile("command"); profile.Token != "" || profile.TokenCommand == "" {
+		t.Errorf("The token command should be kept : %+v", profile)
+	}
+	if profile, _ := config.Profile("fallback"); profile.Token != "plain-fallback" || profile.TokenStore != "memory" {
+		t.Errorf("The fallback token should be kept : %+v", profile)
+	}
+
+	// Finding a profile does not read its token
+	if profile, err := FindProfile("failing"); err != nil || profile.Token != "" {
+		t.Errorf("Unexpected profile : %+v (%v)", profile, err)
+	}
+}
+
 func TestConnect(t *testing.T) {
-	orgs := GetOrgs()
+	ctx := context.Background()
+	GetConfig()
+	client := NewClientFromEnv()
+	if client.BaseURL() == "" {
+		t.Skip("No Grist server configured")
+	}
+
+	orgs, err := client.GetOrgs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
 	nbOrgs := len(orgs)
 
 	if nbOrgs < 2 {
@@ -19,11 +782,18 @@ func TestConnect(t *testing.T) {
 
 	for i, org := range orgs {
 		orgId := fmt.Sprintf("%d", org.Id)
-		if GetOrg(orgId).Name != orgs[i].Name {
+		myOrg, err := client.GetOrg(ctx, orgId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if myOrg.Name != orgs[i].Name {
 			t.Error("We don't find main organization.")
 		}
 
-		workspaces := GetOrgWorkspaces(org.Id)
+		workspaces, err := client.GetOrgWorkspaces(ctx, org.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		if len(workspaces) < 1 {
 			t.Errorf("No workspace in org n°%d", org.Id)
@@ -34,7 +804,10 @@ func TestConnect(t *testing.T) {
 				t.Errorf("Workspace %d : le domaine du workspace %s ne correspond pas à %s", workspace.Id, workspace.OrgDomain, org.Domain)
 			}
 
-			myWorkspace := GetWorkspace(workspace.Id)
+			myWorkspace, err := client.GetWorkspace(ctx, workspace.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
 			if myWorkspace.Name != workspace.Name {
 				t.Errorf("Workspace n°%d : les noms ne correspondent pas (%s/%s)", workspace.Id, workspace.Name, myWorkspace.Name)
 			}
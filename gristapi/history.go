@@ -0,0 +1,54 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"context"
+	"io"
+)
+
+// State of the action history of a document
+type DocState struct {
+	N int    `json:"n"` // Number of the action
+	H string `json:"h"` // Hash of the state
+}
+
+// Get the states of the action history of a document, the most recent first
+func (c *Client) GetDocStates(ctx context.Context, docId string) ([]DocState, error) {
+	var result struct {
+		States []DocState `json:"states"`
+	}
+	err := c.getJSON(ctx, "docs/"+docId+"/states", &result)
+	return result.States, err
+}
+
+// Snapshot of a document, saved by Grist after modifications
+type DocSnapshot struct {
+	SnapshotId   string           `json:"snapshotId"`
+	LastModified string           `json:"lastModified"`
+	DocId        string           `json:"docId"` // Id giving access to the snapshot
+	Metadata     SnapshotMetadata `json:"metadata"`
+}
+
+// Metadata of a document snapshot
+type SnapshotMetadata struct {
+	Label string `json:"label"`
+	H     string `json:"h"` // Hash of the latest state of the snapshot
+}
+
+// Get the snapshots of a document, the most recent first
+func (c *Client) GetDocSnapshots(ctx context.Context, docId string) ([]DocSnapshot, error) {
+	var result struct {
+		Snapshots []DocSnapshot `json:"snapshots"`
+	}
+	err := c.getJSON(ctx, "docs/"+docId+"/snapshots", &result)
+	return result.Snapshots, err
+}
+
+// Returns the size of a document (or of a snapshot) as a .grist file
+// The document is downloaded to count its bytes
+func (c *Client) GetDocSize(ctx context.Context, docId string) (int64, error) {
+	return c.ExportDocGrist(ctx, docId, io.Discard)
+}
@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Record of a Grist table
+// Numbers are decoded as json.Number, to keep the integers intact
+type Record struct {
+	Id     int            `json:"id,omitempty"`
+	Fields map[string]any `json:"fields"`
+}
+
+// Record added or updated by UpsertRecords
+// The record matching the Require values is updated with Fields,
+// or a record is added with Require and Fields values if none matches
+type UpsertRecord struct {
+	Require map[string]any `json:"require"`
+	Fields  map[string]any `json:"fields,omitempty"`
+}
+
+// Selection of the records returned by GetRecords
+type RecordsQuery struct {
+	Filter map[string][]any // Values allowed for each column
+	Sort   string           // Columns to sort by, separated by commas, prefixed by '-' for descending order
+	Limit  int              // Maximum number of records (0 for no limit)
+}
+
+// Returns the URL of the records of a table
+func recordsURL(docId string, tableId string) string {
+	return "docs/" + docId + "/tables/" + tableId + "/records"
+}
+
+// Retrieves the records of a table
+func (c *Client) GetRecords(ctx context.Context, docId string, tableId string, query RecordsQuery) ([]Record, error) {
+	params := url.Values{}
+	if len(query.Filter) > 0 {
+		filter, err := json.Marshal(query.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+		params.Set("filter", string(filter))
+	}
+	if query.Sort != "" {
+		params.Set("sort", query.Sort)
+	}
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+	myRequest := recordsURL(docId, tableId)
+	if len(params) > 0 {
+		myRequest += "?" + params.Encode()
+	}
+
+	response, err := c.httpGet(ctx, myRequest, "")
+	if err != nil {
+		return nil, err
+	}
+	result := struct {
+		Records []Record `json:"records"`
+	}{}
+	decoder := json.NewDecoder(bytes.NewReader(response))
+	decoder.UseNumber()
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response of %s: %w", myRequest, err)
+	}
+	return result.Records, nil
+}
+
+// Adds records to a table
+// The ids of the records are ignored
+// Returns the ids of the new records
+func (c *Client) AddRecords(ctx context.Context, docId string, tableId string, records []Record) ([]int, error) {
+	newRecords := []Record{}
+	for _, record := range records {
+		newRecords = append(newRecords, Record{Fields: record.Fields})
+	}
+	data, err := json.Marshal(map[string]any{"records": newRecords})
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.httpPost(ctx, recordsURL(docId, tableId), string(data))
+	if err != nil {
+		return nil, err
+	}
+	result := struct {
+		Records []struct {
+			Id int `json:"id"`
+		} `json:"records"`
+	}{}
+	if err := json.Unmarshal(response, &result); err != nil {
+		return nil, fmt.Errorf("error decoding response of %s: %w", recordsURL(docId, tableId), err)
+	}
+	ids := []int{}
+	for _, record := range result.Records {
+		ids = append(ids, record.Id)
+	}
+	return ids, nil
+}
+
+// Updates the fields of records of a table, identified by their ids
+func (c *Client) UpdateRecords(ctx context.Context, docId string, tableId string, records []Record) error {
+	for _, record := range records {
+		if record.Id <= 0 {
+			return fmt.Errorf("a record to update has no id")
+		}
+	}
+	data, err := json.Marshal(map[string]any{"records": records})
+	if err != nil {
+		return err
+	}
+	_, err = c.httpPatch(ctx, recordsURL(docId, tableId), string(data))
+	return err
+}
+
+// Adds or updates records of a table
+func (c *Client) UpsertRecords(ctx context.Context, docId string, tableId string, records []UpsertRecord) error {
+	data, err := json.Marshal(map[string]any{"records": records})
+	if err != nil {
+		return err
+	}
+	_, err = c.httpPut(ctx, recordsURL(docId, tableId), string(data))
+	return err
+}
+
+// Deletes records of a table, identified by their ids
+func (c *Client) DeleteRecords(ctx context.Context, docId string, tableId string, ids []int) error {
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	_, err = c.httpPost(ctx, "docs/"+docId+"/tables/"+tableId+"/data/delete", string(data))
+	return err
+}
@@ -0,0 +1,126 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Retry policy of the requests sent to Grist
+//
+// Idempotent requests (GET, PUT, DELETE...) are retried on connection errors
+// and when Grist answers 429, 502, 503 or 504. POST and PATCH requests are only
+// retried when the connection to the server could not be established.
+type RetryPolicy struct {
+	MaxAttempts int           // Maximum number of attempts, including the first one
+	MinBackoff  time.Duration // Wait before the first retry, doubled at each new attempt
+	MaxBackoff  time.Duration // Maximum wait between two attempts
+	Jitter      float64       // Random part of the wait, between 0 and 1
+}
+
+// Retry policy used by default
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  500 * time.Millisecond,
+	MaxBackoff:  30 * time.Second,
+	Jitter:      0.2,
+}
+
+// Policy disabling retries
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// Set the retry policy of the client
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// Returns the wait before the next attempt, after the attempt number n has failed
+// retryAfter is the wait requested by the server (0 if none)
+func (p RetryPolicy) backoff(n int, retryAfter time.Duration) time.Duration {
+	wait := p.MinBackoff
+	for i := 1; i < n && (p.MaxBackoff <= 0 || wait < p.MaxBackoff); i++ {
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	if p.Jitter > 0 && wait > 0 {
+		wait += time.Duration(rand.Float64() * p.Jitter * float64(wait))
+	}
+	// The server knows better when it will be available again
+	if retryAfter > wait {
+		wait = retryAfter
+	}
+	return wait
+}
+
+// Returns true if a request with this method can be sent twice without side effect
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns true if the request can be retried after receiving this HTTP status
+func isRetryableStatus(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return isIdempotent(method)
+	default:
+		return false
+	}
+}
+
+// Returns true if the request can be retried after this transport error
+func isRetryableError(method string, err error) bool {
+	if isIdempotent(method) {
+		return true
+	}
+	// The request was not sent if the connection could not be established
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+// Wait for the given duration, unless the context is canceled before
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Parse the Retry-After header of a response, expressed in seconds or as a date
+func parseRetryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
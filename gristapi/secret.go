@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zalando/go-keyring"
+)
+
+// Backend storing the API tokens outside of the configuration file
+// Tokens are identified by the name of their profile
+type SecretStore interface {
+	Get(profile string) (string, error)
+	Set(profile string, token string) error
+	Delete(profile string) error
+}
+
+// Name of the service under which tokens are stored in the OS keyring
+const keyringService = "gristctl"
+
+// Store using the secret store of the OS:
+// Secret Service (GNOME Keyring, KWallet...) on Linux, Keychain on macOS,
+// Credential Manager on Windows
+// There is no encrypted file backend: on a Linux server without Secret Service,
+// the token is obtained with a command (GRIST_TOKEN_COMMAND) instead
+type keyringStore struct{}
+
+func (keyringStore) Get(profile string) (string, error) {
+	return keyring.Get(keyringService, profile)
+}
+
+func (keyringStore) Set(profile string, token string) error {
+	return keyring.Set(keyringService, profile, token)
+}
+
+func (keyringStore) Delete(profile string) error {
+	err := keyring.Delete(keyringService, profile)
+	if err == keyring.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// Registered secret stores, by name
+var (
+	secretStoresMu sync.RWMutex
+	secretStores   = map[string]SecretStore{
+		"keyring": keyringStore{},
+	}
+)
+
+// Register a secret store, which can then be selected in a profile
+// with GRIST_TOKEN_STORE="<name>"
+func RegisterSecretStore(name string, store SecretStore) {
+	secretStoresMu.Lock()
+	defer secretStoresMu.Unlock()
+	secretStores[name] = store
+}
+
+// Returns the secret store registered with the given name
+func GetSecretStore(name string) (SecretStore, error) {
+	secretStoresMu.RLock()
+	defer secretStoresMu.RUnlock()
+	store, found := secretStores[name]
+	if !found {
+		return nil, fmt.Errorf("unknown token store '%s' (available: %s)", name, strings.Join(secretStoreNames(), ", "))
+	}
+	return store, nil
+}
+
+// Returns the names of the registered secret stores
+func secretStoreNames() []string {
+	names := []string{}
+	for name := range secretStores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run the command printing a token on its standard output
+func runTokenCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("error running token command '%s': %w", command, err)
+	}
+	// Like `pass`, the command may print other lines after the token
+	token, _, _ := strings.Cut(stdout.String(), "\n")
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", fmt.Errorf("token command '%s' did not print any token", command)
+	}
+	return token, nil
+}
+
+// Error returned with the token of the configuration file, when the command
+// or the secret store of the profile failed
+type TokenFallbackError struct {
+	Err error // Error of the command or of the secret store
+}
+
+func (e *TokenFallbackError) Error() string {
+	return e.Err.Error() + ", using the token of the configuration file"
+}
+
+func (e *TokenFallbackError) Unwrap() error {
+	return e.Err
+}
+
+// Returns true if the error only means that the token of the configuration file
+// is used, the token returned with the error being valid
+func IsTokenFallback(err error) bool {
+	var fallbackErr *TokenFallbackError
+	return errors.As(err, &fallbackErr)
+}
+
+// Returns the token of the profile, from the command or the secret store
+// of the profile if any, or else from the configuration file
+// If the command or the store fails, the token of the configuration file
+// is returned, when there is one, with a *TokenFallbackError
+func (p Profile) GetToken() (string, error) {
+	token, err := p.Token, error(nil)
+	switch {
+	case p.TokenCommand != "":
+		token, err = runTokenCommand(p.TokenCommand)
+	case p.TokenStore != "":
+		token, err = readStoredToken(p)
+	}
+	if err != nil && p.Token != "" {
+		return p.Token, &TokenFallbackError{Err: err}
+	}
+	return token, err
+}
+
+// Reads the token of a profile from its secret store
+func readStoredToken(p Profile) (string, error) {
+	store, err := GetSecretStore(p.TokenStore)
+	if err != nil {
+		return "", err
+	}
+	token, err := store.Get(p.Name)
+	if err != nil {
+		return "", fmt.Errorf("error reading the token of profile '%s' from %s: %w", p.Name, p.TokenStore, err)
+	}
+	return token, nil
+}
@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Result of a SQL query
+// Numbers are decoded as json.Number
+type SQLResult struct {
+	Columns []string // Names of the columns, in the order of the query
+	Rows    [][]any  // Values of each row, in the order of the columns
+}
+
+// Runs a read-only SQL query (SELECT) on a document
+// The query can contain `?` parameters, bound to args.
+// If the context has a deadline, the query is interrupted by Grist when it is reached.
+func (c *Client) QuerySQL(ctx context.Context, docId string, sql string, args []any) (SQLResult, error) {
+	result := SQLResult{Columns: []string{}, Rows: [][]any{}}
+	query := map[string]any{"sql": sql}
+	if len(args) > 0 {
+		query["args"] = args
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		query["timeout"] = max(time.Until(deadline).Milliseconds(), 1)
+	}
+	data, err := json.Marshal(query)
+	if err != nil {
+		return result, err
+	}
+	myRequest := "docs/" + docId + "/sql"
+	response, err := c.httpPost(ctx, myRequest, string(data))
+	if err != nil {
+		return result, err
+	}
+
+	records := struct {
+		Records []struct {
+			Fields json.RawMessage `json:"fields"`
+		} `json:"records"`
+	}{}
+	if err := json.Unmarshal(response, &records); err != nil {
+		return result, fmt.Errorf("error decoding response of %s: %w", myRequest, err)
+	}
+	for i, record := range records.Records {
+		columns, values, err := decodeOrderedObject(record.Fields)
+		if err != nil {
+			return result, fmt.Errorf("error decoding response of %s: %w", myRequest, err)
+		}
+		if i == 0 {
+			result.Columns = columns
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result, nil
+}
+
+// Decodes a JSON object, keeping the order of its keys
+func decodeOrderedObject(data []byte) ([]string, []any, error) {
+	keys := []string{}
+	values := []any{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, nil, fmt.Errorf("JSON object expected")
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		var value any
+		if err := decoder.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, token.(string))
+		values = append(values, value)
+	}
+	return keys, values, nil
+}
@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Description of a table, with its columns
+type TableSchema struct {
+	Id      string   `json:"id" yaml:"id"`
+	Columns []Column `json:"columns" yaml:"columns"`
+}
+
+// Description of the tables of a document
+type DocSchema struct {
+	Tables []TableSchema `json:"tables" yaml:"tables"`
+}
+
+// Retrieves the description of the tables of a document, with their columns
+// Hidden columns (manualSort, helper columns) are not included
+func (c *Client) GetDocSchema(ctx context.Context, docId string) (DocSchema, error) {
+	schema := DocSchema{Tables: []TableSchema{}}
+	tables, err := c.GetDocTables(ctx, docId)
+	if err != nil {
+		return schema, err
+	}
+	for _, table := range tables.Tables {
+		columns, err := c.GetTableColumns(ctx, docId, table.Id)
+		if err != nil {
+			return schema, err
+		}
+		schema.Tables = append(schema.Tables, TableSchema{Id: table.Id, Columns: columns.Columns})
+	}
+	return schema, nil
+}
+
+// Identifiers returned by the creation of tables or columns
+type createdIds []struct {
+	Id string `json:"id"`
+}
+
+// Sends a request creating tables or columns, and returns the ids given by Grist
+// (which may differ from the requested ones, e.g. if they were already used)
+func (c *Client) create(ctx context.Context, myRequest string, key string, items any) ([]string, error) {
+	data, err := json.Marshal(map[string]any{key: items})
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.httpPost(ctx, myRequest, string(data))
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]createdIds{}
+	if err := json.Unmarshal(response, &result); err != nil {
+		return nil, fmt.Errorf("error decoding response of %s: %w", myRequest, err)
+	}
+	ids := []string{}
+	for _, item := range result[key] {
+		ids = append(ids, item.Id)
+	}
+	return ids, nil
+}
+
+// Creates tables in a document
+// Returns the ids of the new tables
+func (c *Client) CreateTables(ctx context.Context, docId string, tables []TableSchema) ([]string, error) {
+	return c.create(ctx, "docs/"+docId+"/tables", "tables", tables)
+}
+
+// Adds columns to a table
+// Returns the ids of the new columns
+func (c *Client) AddColumns(ctx context.Context, docId string, tableId string, columns []Column) ([]string, error) {
+	return c.create(ctx, "docs/"+docId+"/tables/"+tableId+"/columns", "columns", columns)
+}
+
+// Modifies the properties of columns of a table
+func (c *Client) UpdateColumns(ctx context.Context, docId string, tableId string, columns []Column) error {
+	data, err := json.Marshal(map[string]any{"columns": columns})
+	if err != nil {
+		return err
+	}
+	_, err = c.httpPatch(ctx, "docs/"+docId+"/tables/"+tableId+"/columns", string(data))
+	return err
+}
+
+// Deletes a table of a document, with its records
+func (c *Client) DeleteTable(ctx context.Context, docId string, tableId string) error {
+	data, err := json.Marshal([][]any{{"RemoveTable", tableId}})
+	if err != nil {
+		return err
+	}
+	_, err = c.httpPost(ctx, "docs/"+docId+"/apply", string(data))
+	return err
+}
+
+// Deletes a column of a table
+func (c *Client) DeleteColumn(ctx context.Context, docId string, tableId string, columnId string) error {
+	_, err := c.httpDelete(ctx, "docs/"+docId+"/tables/"+tableId+"/columns/"+url.PathEscape(columnId), "")
+	return err
+}
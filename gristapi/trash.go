@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"context"
+	"fmt"
+)
+
+// Moves a workspace and its documents to the trash
+func (c *Client) RemoveWorkspace(ctx context.Context, workspaceId int) error {
+	_, err := c.httpPost(ctx, fmt.Sprintf("workspaces/%d/remove", workspaceId), "")
+	return err
+}
+
+// Restores a workspace from the trash
+func (c *Client) UnremoveWorkspace(ctx context.Context, workspaceId int) error {
+	_, err := c.httpPost(ctx, fmt.Sprintf("workspaces/%d/unremove", workspaceId), "")
+	return err
+}
+
+// Moves a document to the trash
+func (c *Client) RemoveDoc(ctx context.Context, docId string) error {
+	_, err := c.httpPost(ctx, "docs/"+docId+"/remove", "")
+	return err
+}
+
+// Restores a document from the trash
+func (c *Client) UnremoveDoc(ctx context.Context, docId string) error {
+	_, err := c.httpPost(ctx, "docs/"+docId+"/unremove", "")
+	return err
+}
+
+// Get the workspaces and documents of an organization which are in the trash
+// The documents of a removed workspace are all listed, the other workspaces
+// are listed with their removed documents only
+func (c *Client) GetTrash(ctx context.Context, orgId int) ([]Workspace, error) {
+	workspaces := []Workspace{}
+	err := c.getJSON(ctx, fmt.Sprintf("orgs/%d/workspaces?showRemoved=1", orgId), &workspaces)
+	if err != nil {
+		return nil, err
+	}
+	trash := []Workspace{}
+	for _, workspace := range workspaces {
+		docs := []Doc{}
+		for _, doc := range workspace.Docs {
+			if doc.RemovedAt != "" || workspace.RemovedAt != "" {
+				docs = append(docs, doc)
+			}
+		}
+		if workspace.RemovedAt != "" || len(docs) > 0 {
+			workspace.Docs = docs
+			trash = append(trash, workspace)
+		}
+	}
+	return trash, nil
+}
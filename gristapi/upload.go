@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+)
+
+// Extensions of the files which can be imported as documents
+var ImportExtensions = []string{".grist", ".xlsx", ".csv"}
+
+// Returns true if the file can be imported as a document
+func IsImportable(fileName string) bool {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, importExt := range ImportExtensions {
+		if ext == importExt {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes a multipart body uploading content in the "upload" field
+func writeUpload(w io.Writer, boundary string, uploadName string, fileName string, content io.Reader) error {
+	writer := multipart.NewWriter(w)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return err
+	}
+	part, err := writer.CreateFormFile("upload", uploadName)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, content); err != nil {
+		return fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+	return writer.Close()
+}
+
+// Imports a file (.grist, .xlsx or .csv) as a new document of a workspace
+// The document is named after the file, or after name if it is not empty
+// Returns the id of the new document
+func (c *Client) ImportDoc(ctx context.Context, workspaceId int, fileName string, content io.Reader, name string) (string, error) {
+	if !IsImportable(fileName) {
+		return "", fmt.Errorf("%s cannot be imported (allowed extensions: %s)", fileName, strings.Join(ImportExtensions, ", "))
+	}
+	// Grist names the document after the uploaded file
+	uploadName := filepath.Base(fileName)
+	if name != "" {
+		uploadName = name + filepath.Ext(fileName)
+	}
+
+	// The body is streamed while the file is read, and a file which can be
+	// read again from its start (io.Seeker) can be sent again on retries
+	seeker, rewindable := content.(io.Seeker)
+	start := int64(0)
+	if rewindable {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			rewindable = false
+		}
+	}
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	var reader *io.PipeReader
+	var done chan struct{}
+	stop := func() {
+		// The transport may close the body of a request after returning its response
+		if reader != nil {
+			reader.Close()
+			<-done
+		}
+	}
+	defer stop()
+	body := func() (io.Reader, error) {
+		stop()
+		if rewindable {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("error reading %s: %w", fileName, err)
+			}
+		}
+		var writer *io.PipeWriter
+		reader, writer = io.Pipe()
+		done = make(chan struct{})
+		go func(done chan struct{}) {
+			defer close(done)
+			writer.CloseWithError(writeUpload(writer, boundary, uploadName, fileName, content))
+		}(done)
+		return reader, nil
+	}
+
+	myRequest := fmt.Sprintf("workspaces/%d/import", workspaceId)
+	resp, err := c.sendBody(ctx, "POST", myRequest, "multipart/form-data; boundary="+boundary, body, rewindable)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	result := struct {
+		Id string `json:"id"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || result.Id == "" {
+		return "", fmt.Errorf("unexpected response when importing %s", fileName)
+	}
+	return result.Id, nil
+}
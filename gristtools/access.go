@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"fmt"
+	"gristctl/gristapi"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Roles which can be given on each kind of entity
+var entityRoles = map[string][]string{
+	"org":       {"owners", "editors", "viewers", "members"},
+	"workspace": {"owners", "editors", "viewers"},
+	"doc":       {"owners", "editors", "viewers"},
+}
+
+// Values of the maximum role inherited by workspaces and documents
+var inheritanceRoles = []string{"owners", "editors", "viewers", "none"}
+
+// Names of the kinds of entities, used in messages
+var entityNames = map[string]string{"org": "organization", "workspace": "workspace", "doc": "document"}
+
+// Checks that a role can be given on a kind of entity
+func checkRole(entity string, role string) error {
+	roles, found := entityRoles[entity]
+	if !found {
+		return fmt.Errorf("invalid entity '%s', expected org, workspace or doc", entity)
+	}
+	if !slices.Contains(roles, role) {
+		return fmt.Errorf("invalid role '%s' for %s, expected %s", role, entityNames[entity], strings.Join(roles, ", "))
+	}
+	return nil
+}
+
+// Returns the numeric id of an organization or a workspace
+func entityId(entity string, id string) (int, error) {
+	intId, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s id '%s'", entityNames[entity], id)
+	}
+	return intId, nil
+}
+
+// Retrieves the access rights of an organization, a workspace or a document
+// Organizations have no inherited role
+func getAccess(entity string, id string) (gristapi.EntityAccess, error) {
+	var access gristapi.EntityAccess
+	var err error
+	switch entity {
+	case "org":
+		if _, err := entityId(entity, id); err != nil {
+			return access, err
+		}
+		access.Users, err = client.GetOrgAccess(ctx, id)
+	case "workspace":
+		intId, errId := entityId(entity, id)
+		if errId != nil {
+			return access, errId
+		}
+		access, err = client.GetWorkspaceAccess(ctx, intId)
+	case "doc":
+		access, err = client.GetDocAccess(ctx, id)
+	default:
+		return access, fmt.Errorf("invalid entity '%s', expected org, workspace or doc", entity)
+	}
+	if err != nil {
+		return access, entityError(entityNames[entity], id, err)
+	}
+	return access, nil
+}
+
+// Applies changes to the access rights of an organization, a workspace or a document
+func updateAccess(entity string, id string, delta gristapi.AccessDelta) error {
+	var err error
+	switch entity {
+	case "org", "workspace":
+		intId, errId := entityId(entity, id)
+		if errId != nil {
+			return errId
+		}
+		if entity == "org" {
+			err = client.UpdateOrgAccess(ctx, intId, delta)
+		} else {
+			err = client.UpdateWorkspaceAccess(ctx, intId, delta)
+		}
+	case "doc":
+		err = client.UpdateDocAccess(ctx, id, delta)
+	default:
+		return fmt.Errorf("invalid entity '%s', expected org, workspace or doc", entity)
+	}
+	if err != nil {
+		return entityError(entityNames[entity], id, err)
+	}
+	return nil
+}
+
+// Displays the access rights of an organization, a workspace or a document
+func displayAccess(entity string, id string) error {
+	switch entity {
+	case "org":
+		return DisplayOrgAccess(id)
+	case "workspace":
+		workspaceId, _ := strconv.Atoi(id)
+		return DisplayWorkspaceAccess(workspaceId)
+	default:
+		return DisplayDocAccess(id)
+	}
+}
+
+// Gives a role to a user on an organization, a workspace or a document,
+// then displays the resulting access rights
+func SetAccess(entity string, id string, email string, role string) error {
+	if err := checkRole(entity, role); err != nil {
+		return err
+	}
+	delta := gristapi.AccessDelta{Users: map[string]*string{email: &role}}
+	if err := updateAccess(entity, id, delta); err != nil {
+		return err
+	}
+	fmt.Printf("Role %s given to %s on %s %s ✅\n", role, email, entityNames[entity], id)
+	return displayAccess(entity, id)
+}
+
+// Removes the access of a user to an organization, a workspace or a document,
+// then displays the resulting access rights
+func RevokeAccess(entity string, id string, email string) error {
+	delta := gristapi.AccessDelta{Users: map[string]*string{email: nil}}
+	if err := updateAccess(entity, id, delta); err != nil {
+		return err
+	}
+	fmt.Printf("Access of %s to %s %s revoked ✅\n", email, entityNames[entity], id)
+	return displayAccess(entity, id)
+}
+
+// Sets the maximum role inherited by a workspace or a document from its parent
+// ("none" to inherit no access), then displays the resulting access rights
+func SetInheritance(entity string, id string, role string) error {
+	if entity != "workspace" && entity != "doc" {
+		return fmt.Errorf("invalid entity '%s', expected workspace or doc", entity)
+	}
+	if !slices.Contains(inheritanceRoles, role) {
+		return fmt.Errorf("invalid inheritance '%s', expected %s", role, strings.Join(inheritanceRoles, ", "))
+	}
+	maxInheritedRole := role
+	if role == "none" {
+		maxInheritedRole = ""
+	}
+	if err := updateAccess(entity, id, gristapi.AccessDelta{MaxInheritedRole: &maxInheritedRole}); err != nil {
+		return err
+	}
+	fmt.Printf("Inheritance of %s %s set to %s ✅\n", entityNames[entity], id, role)
+	return displayAccess(entity, id)
+}
@@ -0,0 +1,436 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gristctl/gristapi"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Name of the manifest file of a backup
+const manifestFileName = "manifest.json"
+
+// Format of the names of the backup directories
+const backupDirFormat = "20060102-150405"
+
+// Description of a backup, saved in its manifest.json file
+type backupManifest struct {
+	Server     string      `json:"server"`
+	StartedAt  time.Time   `json:"startedAt"`
+	FinishedAt time.Time   `json:"finishedAt"`
+	Docs       []backupDoc `json:"docs"`
+}
+
+// Backup of a document
+type backupDoc struct {
+	OrgId         int    `json:"orgId"`
+	OrgName       string `json:"orgName"`
+	WorkspaceId   int    `json:"workspaceId"`
+	WorkspaceName string `json:"workspaceName"`
+	DocId         string `json:"docId"`
+	DocName       string `json:"docName"`
+	UpdatedAt     string `json:"updatedAt"`            // Last modification of the document
+	StateHash     string `json:"stateHash,omitempty"`  // Hash of the latest state of the document history
+	File          string `json:"file,omitempty"`       // Path of the .grist file, relative to the backup directory
+	Size          int64  `json:"size,omitempty"`       // Size of the file, in bytes
+	SHA256        string `json:"sha256,omitempty"`     // Checksum of the file
+	BackedUpAt    string `json:"backedUpAt,omitempty"` // End of the download
+	Error         string `json:"error,omitempty"`      // Error preventing the backup of the document
+	Unchanged     bool   `json:"unchanged,omitempty"`  // File reused from the previous backup
+}
+
+// Directory of a backup, in the destination directory
+type backupDir struct {
+	name string
+	date time.Time
+}
+
+// Characters which cannot be used in file names on every system
+var unsafeFileChars = regexp.MustCompile(`[/\\:*?"<>|\x00-\x1f]+`)
+
+// Returns a file name made of an id and a name, usable on every system
+func safeFileName(id string, name string) string {
+	name = unsafeFileChars.ReplaceAllString(name, "_")
+	if name == "" {
+		return id
+	}
+	return id + "_" + name
+}
+
+// Calls fn for each index from 0 to count-1, running at most concurrency calls at a time
+func forEachConcurrently(count int, concurrency int, fn func(i int)) {
+	semaphore := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
+
+// Lists the documents to back up, in the organization orgId ("all" for every organization)
+func listBackupDocs(orgId string) ([]backupDoc, error) {
+	orgs := []gristapi.Org{}
+	if orgId == "all" {
+		var err error
+		if orgs, err = client.GetOrgs(ctx); err != nil {
+			return nil, err
+		}
+	} else {
+		org, err := client.GetOrg(ctx, orgId)
+		if err != nil {
+			return nil, entityError("organization", orgId, err)
+		}
+		orgs = append(orgs, org)
+	}
+
+	docs := []backupDoc{}
+	for _, org := range orgs {
+		workspaces, err := client.GetOrgWorkspaces(ctx, org.Id)
+		if err != nil {
+			return nil, entityError("organization", org.Id, err)
+		}
+		for _, workspace := range workspaces {
+			for _, doc := range workspace.Docs {
+				docs = append(docs, backupDoc{
+					OrgId:         org.Id,
+					OrgName:       org.Name,
+					WorkspaceId:   workspace.Id,
+					WorkspaceName: workspace.Name,
+					DocId:         doc.Id,
+					DocName:       doc.Name,
+					UpdatedAt:     doc.UpdatedAt,
+				})
+			}
+		}
+	}
+	return docs, nil
+}
+
+// Returns true if a document has not changed since a previous backup,
+// by comparing the hash of its latest state, or its modification date
+func sameDocVersion(previous backupDoc, doc backupDoc) bool {
+	if previous.Error != "" || previous.File == "" {
+		return false
+	}
+	if previous.StateHash != "" && doc.StateHash != "" {
+		return previous.StateHash == doc.StateHash
+	}
+	return previous.UpdatedAt != "" && previous.UpdatedAt == doc.UpdatedAt
+}
+
+// Creates a hard link to a file of a previous backup,
+// or a copy if hard links are not supported
+func linkFile(source string, target string) error {
+	if err := os.Link(source, target); err == nil {
+		return nil
+	}
+	file, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = gristapi.SaveToFile(target, func(w io.Writer) (int64, error) {
+		return io.Copy(w, file)
+	})
+	return err
+}
+
+/*
+Downloads a document in the backup directory
+The path, size and checksum of the file are set in doc
+
+If the document has not changed since the previous backup (given by its
+directory and the documents of its manifest), its file is reused.
+*/
+func backupDocument(backupDir string, doc *backupDoc, previousDir string, previousDocs map[string]backupDoc) error {
+	// Without history (not allowed to the user), the modification date is compared
+	if states, err := client.GetDocStates(ctx, doc.DocId); err == nil && len(states) > 0 {
+		doc.StateHash = states[0].H
+	}
+	doc.File = filepath.Join(
+		safeFileName(strconv.Itoa(doc.OrgId), doc.OrgName),
+		safeFileName(strconv.Itoa(doc.WorkspaceId), doc.WorkspaceName),
+		safeFileName(doc.DocId, doc.DocName)+".grist",
+	)
+	fileName := filepath.Join(backupDir, doc.File)
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+
+	if previous, found := previousDocs[doc.DocId]; found && sameDocVersion(previous, *doc) {
+		if err := linkFile(filepath.Join(previousDir, previous.File), fileName); err == nil {
+			doc.Size, doc.SHA256, doc.BackedUpAt = previous.Size, previous.SHA256, previous.BackedUpAt
+			doc.Unchanged = true
+			return nil
+		}
+		// The document is downloaded if the previous file cannot be reused
+	}
+
+	hash := sha256.New()
+	size, err := gristapi.SaveToFile(fileName, func(w io.Writer) (int64, error) {
+		return client.ExportDocGrist(ctx, doc.DocId, io.MultiWriter(w, hash))
+	})
+	if err != nil {
+		return entityError("document", doc.DocId, err)
+	}
+	doc.Size = size
+	doc.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	doc.BackedUpAt = time.Now().UTC().Format(time.RFC3339)
+	return nil
+}
+
+// Writes a value in a JSON file, replaced only once completely written
+func saveJSON(fileName string, value any) error {
+	content, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = gristapi.SaveToFile(fileName, func(w io.Writer) (int64, error) {
+		n, err := w.Write(content)
+		return int64(n), err
+	})
+	return err
+}
+
+// Writes the manifest of a backup
+func (m *backupManifest) save(backupDir string) error {
+	return saveJSON(filepath.Join(backupDir, manifestFileName), m)
+}
+
+// Lists the backups of the destination directory, the most recent first
+// Only the directories with a manifest are listed
+func listBackupDirs(dest string) ([]backupDir, error) {
+	entries, err := os.ReadDir(dest)
+	if err != nil {
+		return nil, err
+	}
+	dirs := []backupDir{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		date, err := time.ParseInLocation(backupDirFormat, entry.Name(), time.Local)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dest, entry.Name(), manifestFileName)); err != nil {
+			continue
+		}
+		dirs = append(dirs, backupDir{name: entry.Name(), date: date})
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].date.After(dirs[j].date) })
+	return dirs, nil
+}
+
+// Reads the manifest of a backup
+func readManifest(dir string) (backupManifest, error) {
+	manifest := backupManifest{}
+	content, err := os.ReadFile(filepath.Join(dir, manifestFileName))
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return manifest, fmt.Errorf("invalid manifest %s: %w", filepath.Join(dir, manifestFileName), err)
+	}
+	return manifest, nil
+}
+
+// Finds the most recent backup of the server in the destination directory
+// Returns its directory and its documents, by id (none if there is no previous backup)
+func previousBackup(dest string) (string, map[string]backupDoc, error) {
+	docs := map[string]backupDoc{}
+	dirs, err := listBackupDirs(dest)
+	if err != nil {
+		return "", docs, err
+	}
+	for _, dir := range dirs {
+		manifest, err := readManifest(filepath.Join(dest, dir.name))
+		if err != nil {
+			return "", docs, err
+		}
+		if manifest.Server != client.BaseURL() {
+			continue
+		}
+		for _, doc := range manifest.Docs {
+			docs[doc.DocId] = doc
+		}
+		return filepath.Join(dest, dir.name), docs, nil
+	}
+	return "", docs, nil
+}
+
+/*
+Removes the old backups of the server in the destination directory, keeping
+the most recent backup of each of the last keepDaily days and of each of the
+last keepWeekly weeks
+The backups of other servers, and the directories whose manifest cannot be
+read, are left untouched.
+Returns the removed directories
+*/
+func pruneBackups(dest string, server string, keepDaily int, keepWeekly int) ([]string, error) {
+	dirs, err := listBackupDirs(dest)
+	if err != nil {
+		return nil, err
+	}
+	days := map[string]bool{}
+	weeks := map[string]bool{}
+	removed := []string{}
+	for _, dir := range dirs {
+		if manifest, err := readManifest(filepath.Join(dest, dir.name)); err != nil || manifest.Server != server {
+			continue
+		}
+		keep := false
+		day := dir.date.Format("2006-01-02")
+		if len(days) < keepDaily && !days[day] {
+			days[day] = true
+			keep = true
+		}
+		year, week := dir.date.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%02d", year, week)
+		if len(weeks) < keepWeekly && !weeks[weekKey] {
+			weeks[weekKey] = true
+			keep = true
+		}
+		if keep {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dest, dir.name)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, filepath.Join(dest, dir.name))
+	}
+	return removed, nil
+}
+
+/*
+Backs up the documents of an organization (or of all organizations with "all")
+in a new directory of dest, named after the date of the backup
+
+The documents are downloaded concurrently, in a <org>/<workspace>/<doc>.grist
+tree. The manifest.json file lists the documents with their size and checksum.
+
+With incremental, the documents which have not changed since the previous
+backup are not downloaded: their files are hard links to the previous ones.
+With keepDaily or keepWeekly, the backups of the server which are neither
+among the daily backups of the last keepDaily days nor among the weekly
+backups of the last keepWeekly weeks are then removed, unless some documents
+could not be backed up.
+*/
+func Backup(orgId string, dest string, concurrency int, incremental bool, keepDaily int, keepWeekly int) error {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	previousDir, previousDocs := "", map[string]backupDoc{}
+	if incremental {
+		var err error
+		if previousDir, previousDocs, err = previousBackup(dest); err != nil {
+			return err
+		}
+	}
+
+	startedAt := time.Now().UTC()
+	backupDir := filepath.Join(dest, startedAt.Local().Format(backupDirFormat))
+	if err := os.Mkdir(backupDir, 0755); err != nil {
+		return err
+	}
+	docs, err := listBackupDocs(orgId)
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	nbErrors, nbUnchanged := 0, 0
+	forEachConcurrently(len(docs), concurrency, func(i int) {
+		doc := &docs[i]
+		err := backupDocument(backupDir, doc, previousDir, previousDocs)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			doc.File = ""
+			doc.Error = err.Error()
+			nbErrors++
+		} else if doc.Unchanged {
+			nbUnchanged++
+		}
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	manifest := backupManifest{Server: client.BaseURL(), StartedAt: startedAt, FinishedAt: time.Now().UTC(), Docs: docs}
+	if err := manifest.save(backupDir); err != nil {
+		return fmt.Errorf("error writing the manifest of the backup: %w", err)
+	}
+	if err := displayBackup(manifest); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Backup of %d documents (%d unchanged) in %s\n", len(docs)-nbErrors, nbUnchanged, backupDir)
+
+	// An incomplete backup must not replace a complete one
+	if (keepDaily > 0 || keepWeekly > 0) && nbErrors > 0 {
+		fmt.Fprintln(os.Stderr, "Old backups are kept, as some documents could not be backed up")
+	} else if keepDaily > 0 || keepWeekly > 0 {
+		removed, err := pruneBackups(dest, client.BaseURL(), keepDaily, keepWeekly)
+		for _, dir := range removed {
+			fmt.Fprintf(os.Stderr, "Old backup %s removed\n", dir)
+		}
+		if err != nil {
+			return fmt.Errorf("error removing old backups: %w", err)
+		}
+	}
+	if nbErrors > 0 {
+		return fmt.Errorf("%d of %d documents could not be backed up", nbErrors, len(docs))
+	}
+	return nil
+}
+
+// Displays the documents of a backup
+func displayBackup(manifest backupManifest) error {
+	switch output {
+	case "table":
+		{
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Organization", "Workspace", "Document", "Size", "Status"})
+			for _, doc := range manifest.Docs {
+				status := "✅"
+				if doc.Unchanged {
+					status = "✅ (unchanged)"
+				} else if doc.Error != "" {
+					status = "❗️ " + doc.Error
+				}
+				table.Append([]string{doc.OrgName, doc.WorkspaceName, fmt.Sprintf("%s (%s)", doc.DocName, doc.DocId), strconv.FormatInt(doc.Size, 10), status})
+			}
+			table.Render()
+		}
+	case "json":
+		{
+			jsonManifest, err := json.MarshalIndent(manifest, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(jsonManifest))
+		}
+	}
+	return nil
+}
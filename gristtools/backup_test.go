@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestPruneBackups(t *testing.T) {
+	const server = "https://grist.example.com"
+	// Backups of the server, from the most recent one
+	backups := []string{"20240311-100000", "20240311-080000", "20240310-100000", "20240309-100000", "20240304-100000", "20240226-100000"}
+
+	tests := []struct {
+		name       string
+		keepDaily  int
+		keepWeekly int
+		kept       []string
+	}{
+		{"daily", 2, 0, []string{"20240311-100000", "20240310-100000"}},
+		{"daily and weekly", 1, 2, []string{"20240311-100000", "20240310-100000"}},
+		{"weekly", 0, 3, []string{"20240311-100000", "20240310-100000", "20240226-100000"}},
+		{"more days than backups", 10, 0, []string{"20240311-100000", "20240310-100000", "20240309-100000", "20240304-100000", "20240226-100000"}},
+	}
+	for _, test := range tests {
+		dest := t.TempDir()
+		for _, name := range backups {
+			manifest := backupManifest{Server: server, Docs: []backupDoc{}}
+			if err := os.Mkdir(filepath.Join(dest, name), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := manifest.save(filepath.Join(dest, name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Old backup of another server, and backup whose manifest cannot be read
+		other := backupManifest{Server: "https://other.example.com", Docs: []backupDoc{}}
+		os.Mkdir(filepath.Join(dest, "20240101-100000"), 0755)
+		other.save(filepath.Join(dest, "20240101-100000"))
+		os.Mkdir(filepath.Join(dest, "20240102-100000"), 0755)
+		os.WriteFile(filepath.Join(dest, "20240102-100000", manifestFileName), []byte("{"), 0644)
+
+		removed, err := pruneBackups(dest, server, test.keepDaily, test.keepWeekly)
+		if err != nil {
+			t.Errorf("%s: error pruning the backups : %s", test.name, err)
+			continue
+		}
+		remaining := []string{}
+		for _, name := range backups {
+			if _, err := os.Stat(filepath.Join(dest, name)); err == nil {
+				remaining = append(remaining, name)
+			}
+		}
+		if !slices.Equal(remaining, test.kept) {
+			t.Errorf("%s: unexpected kept backups %v, expected %v", test.name, remaining, test.kept)
+		}
+		if len(removed) != len(backups)-len(test.kept) {
+			t.Errorf("%s: unexpected removed backups %v", test.name, removed)
+		}
+		for _, name := range []string{"20240101-100000", "20240102-100000"} {
+			if _, err := os.Stat(filepath.Join(dest, name)); err != nil {
+				t.Errorf("%s: backup %s should be kept", test.name, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"encoding/json"
+	"fmt"
+	"gristctl/common"
+	"gristctl/gristapi"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Profile configured with the --profile option (empty for the current profile)
+var profileName string
+
+// Set the name of the profile selected on the command line
+func SetProfile(name string) {
+	profileName = name
+}
+
+/*
+Configure Grist envfile (url and api token)
+Interactive filling of a profile of the `.gristctl` file
+The profile selected with --profile is configured, or else the current one
+*/
+func Config() error {
+	configFile := gristapi.ConfigPath()
+	config, err := gristapi.LoadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	name := profileName
+	if name == "" {
+		name = config.CurrentName()
+	}
+	if err := gristapi.ValidateProfileName(name); err != nil {
+		return err
+	}
+	profile, _ := config.Profile(name)
+
+	common.DisplayTitle(fmt.Sprintf("%s (%s, %s '%s')", common.T("config.title"), configFile, common.T("config.profile"), name))
+	if insecure, _ := gristapi.IsConfigReadableByOthers(configFile); insecure {
+		fmt.Printf("⚠️  %s (chmod 600 %s)\n", common.T("config.insecure"), configFile)
+	}
+	fmt.Printf("%s :\n- URL : %s\n", common.T("config.actual"), profile.URL)
+	fmt.Printf("- %s : %s\n", common.T("config.token"), describeToken(profile))
+	testConnect := "❌"
+	if token, err := profile.GetToken(); WarnTokenFallback(err) == nil && profile.URL != "" && gristapi.NewClient(profile.URL, token).TestConnection(ctx) {
+		testConnect = "✅"
+	}
+	fmt.Printf("%s : %s\n", common.T("config.connectTest"), testConnect)
+
+	if common.Confirm(common.T("config.config")) {
+		var url string
+		urlSet := false
+		for urlSet == false {
+			url = common.Ask(common.T("config.urlSet"))
+
+			// Test if url is well formatted
+			urlOk, _ := regexp.MatchString(`^https?://.*[^/]$`, url)
+			urlSet = urlOk
+		}
+		newProfile := gristapi.Profile{Name: name, URL: url}
+		token := ""
+		switch common.Ask(common.T("config.tokenStorage")) {
+		case "keyring":
+			newProfile.TokenStore = "keyring"
+			token = common.Ask(common.T("config.token"))
+		case "command":
+			newProfile.TokenCommand = common.Ask(common.T("config.tokenCommand"))
+		default:
+			token = common.Ask(common.T("config.token"))
+			newProfile.Token = token
+		}
+		if common.Confirm(fmt.Sprintf("\n%s :\n- URL : %s\n- Token: %s\n%s ", common.T("config.new"), url, describeToken(newProfile), common.T("questions.isOk"))) {
+			if newProfile.TokenStore != "" {
+				if err := storeToken(newProfile, token); err != nil {
+					return err
+				}
+			}
+			// The token previously stored is not used anymore
+			if profile.TokenStore != "" && profile.TokenStore != newProfile.TokenStore {
+				deleteToken(profile)
+			}
+			// The first profile becomes the current one; adding another profile,
+			// even to a file of the previous format, does not change the current profile
+			if config.Current == "" && len(config.Profiles) == 0 {
+				config.Current = name
+			}
+			config.SetProfile(newProfile)
+			if err := config.Save(configFile); err != nil {
+				return fmt.Errorf("%s %s (%w)", common.T("config.saveError"), configFile, err)
+			}
+			fmt.Printf("%s %s\n", common.T("config.savedIn"), configFile)
+
+			// Test the configuration by connecting to the server
+			token, err := newProfile.GetToken()
+			if err = WarnTokenFallback(err); err != nil {
+				return err
+			}
+			client = gristapi.NewClient(url, token)
+			orgs, err := client.GetOrgs(ctx)
+			if err != nil {
+				fmt.Println(common.T("config.connectError"))
+				return err
+			}
+			fmt.Printf("Nb orgs : %d\n", len(orgs))
+		}
+	}
+	return nil
+}
+
+// Describes where the token of a profile comes from, without revealing it
+func describeToken(profile gristapi.Profile) string {
+	switch {
+	case profile.TokenCommand != "":
+		return fmt.Sprintf("%s `%s`", common.T("config.tokenFromCommand"), profile.TokenCommand)
+	case profile.TokenStore != "":
+		return fmt.Sprintf("%s %s", common.T("config.tokenInStore"), profile.TokenStore)
+	default:
+		return strings.Repeat("•", len(profile.Token))
+	}
+}
+
+// Save the token of a profile in its secret store
+func storeToken(profile gristapi.Profile, token string) error {
+	store, err := gristapi.GetSecretStore(profile.TokenStore)
+	if err != nil {
+		return err
+	}
+	if err := store.Set(profile.Name, token); err != nil {
+		return fmt.Errorf("error saving the token in %s: %w", profile.TokenStore, err)
+	}
+	return nil
+}
+
+// Remove the token of a profile from its secret store, if any
+func deleteToken(profile gristapi.Profile) {
+	if profile.TokenStore == "" {
+		return
+	}
+	store, err := gristapi.GetSecretStore(profile.TokenStore)
+	if err == nil {
+		err = store.Delete(profile.Name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error removing the token of profile '%s' from %s: %s\n", profile.Name, profile.TokenStore, err)
+	}
+}
+
+// Add a profile to the configuration file, interactively
+func ConfigAdd(name string) error {
+	profileName = name
+	return Config()
+}
+
+// Displays the list of profiles, the current one being marked with a star
+func ConfigList() error {
+	type profileDesc struct {
+		Name    string `json:"name"`
+		URL     string `json:"url"`
+		Current bool   `json:"current"`
+	}
+
+	config, err := gristapi.LoadConfig(gristapi.ConfigPath())
+	if err != nil {
+		return err
+	}
+	lstProfiles := []profileDesc{}
+	for _, profile := range config.Profiles {
+		lstProfiles = append(lstProfiles, profileDesc{profile.Name, profile.URL, profile.Name == config.CurrentName()})
+	}
+	sort.Slice(lstProfiles, func(i, j int) bool {
+		return lstProfiles[i].Name < lstProfiles[j].Name
+	})
+
+	switch output {
+	case "table":
+		{
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{common.T("config.current"), common.T("config.profile"), "URL"})
+			for _, profile := range lstProfiles {
+				current := ""
+				if profile.Current {
+					current = "*"
+				}
+				table.Append([]string{current, profile.Name, profile.URL})
+			}
+			table.Render()
+		}
+	case "json":
+		{
+			jsonProfiles, err := json.MarshalIndent(lstProfiles, "", "  ")
+			if err != nil {
+				fmt.Println("ERROR :", err)
+			}
+			fmt.Println(string(jsonProfiles))
+		}
+	}
+	return nil
+}
+
+// Select the profile used by default
+func ConfigUse(name string) error {
+	configFile := gristapi.ConfigPath()
+	config, err := gristapi.LoadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	if _, found := config.Profile(name); !found {
+		return fmt.Errorf("profile '%s' not found in %s", name, configFile)
+	}
+	config.Current = name
+	if err := config.Save(configFile); err != nil {
+		return err
+	}
+	fmt.Printf("Profile '%s' is now the current profile ✅\n", name)
+	return nil
+}
+
+// Remove a profile from the configuration file
+func ConfigRemove(name string) error {
+	configFile := gristapi.ConfigPath()
+	config, err := gristapi.LoadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	profile, found := config.Profile(name)
+	if !found {
+		return fmt.Errorf("profile '%s' not found in %s", name, configFile)
+	}
+	if common.Confirm(fmt.Sprintf("Do you really want to remove profile '%s' ?", name)) {
+		deleteToken(profile)
+		config.RemoveProfile(name)
+		if err := config.Save(configFile); err != nil {
+			return err
+		}
+		fmt.Printf("Profile '%s' removed ✅\n", name)
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"gristctl/gristapi"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Kinds of record changes
+const (
+	recordInserted = "inserted"
+	recordDeleted  = "deleted"
+	recordModified = "modified"
+)
+
+// Change of a record between two tables
+type recordChange struct {
+	Change string               `json:"change"`
+	Key    string               `json:"key"`
+	Record map[string]any       `json:"record,omitempty"` // Inserted or deleted record
+	Fields map[string]valueDiff `json:"fields,omitempty"` // Modified fields
+}
+
+// Old and new values of a field
+type valueDiff struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// Table compared by diff data: a table of a document (<doc id>/<table>)
+// or a JSON or CSV file
+type dataSource struct {
+	name    string
+	docId   string
+	tableId string
+	file    string
+}
+
+// Parses the description of a compared table
+func parseDataSource(name string) (dataSource, error) {
+	if _, err := os.Stat(name); err == nil {
+		return dataSource{name: name, file: name}, nil
+	}
+	docId, tableId, found := strings.Cut(name, "/")
+	if !found || docId == "" || tableId == "" {
+		return dataSource{}, fmt.Errorf("'%s' is neither a file nor a table (<doc id>/<table>)", name)
+	}
+	return dataSource{name: name, docId: docId, tableId: tableId}, nil
+}
+
+// Returns the types of the columns of the table (empty for a file)
+func (s dataSource) columnTypes() (map[string]string, error) {
+	types := map[string]string{}
+	if s.file != "" {
+		return types, nil
+	}
+	columns, err := client.GetTableColumns(ctx, s.docId, s.tableId)
+	if err != nil {
+		return nil, entityError("table", s.name, err)
+	}
+	for _, column := range columns.Columns {
+		types[column.Id] = column.Fields.Type
+	}
+	return types, nil
+}
+
+// Reads the records of the table
+// types is used to convert the values of CSV files
+func (s dataSource) records(types map[string]string) ([]gristapi.Record, error) {
+	if s.file == "" {
+		records, err := client.GetRecords(ctx, s.docId, s.tableId, gristapi.RecordsQuery{})
+		if err != nil {
+			return nil, entityError("table", s.name, err)
+		}
+		return records, nil
+	}
+
+	content, err := os.ReadFile(s.file)
+	if err != nil {
+		return nil, err
+	}
+	var input []inputRecord
+	if strings.EqualFold(filepath.Ext(s.file), ".json") {
+		input, err = parseJSONRecords(content)
+	} else {
+		input, err = parseCSVRecords(content, types)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.file, err)
+	}
+	records := []gristapi.Record{}
+	for _, record := range input {
+		records = append(records, gristapi.Record{Id: record.Id, Fields: record.Fields})
+	}
+	return records, nil
+}
+
+// Returns the key of a record, made of the values of the key columns
+func recordKey(record gristapi.Record, keys []string) (string, error) {
+	values := []string{}
+	for _, key := range keys {
+		if key == "id" {
+			values = append(values, strconv.Itoa(record.Id))
+			continue
+		}
+		value, found := record.Fields[key]
+		if !found {
+			return "", fmt.Errorf("key column '%s' not found", key)
+		}
+		values = append(values, formatValue(value))
+	}
+	return strings.Join(values, "|"), nil
+}
+
+// Returns true if two values of a field are the same
+// Numbers are compared by value, whatever their representation (1, 1.0)
+func sameValue(a any, b any) bool {
+	toFloat := func(value any) (float64, bool) {
+		switch v := value.(type) {
+		case json.Number:
+			f, err := v.Float64()
+			return f, err == nil
+		case float64:
+			return v, true
+		case int:
+			return float64(v), true
+		case int64:
+			return float64(v), true
+		}
+		return 0, false
+	}
+	if floatA, ok := toFloat(a); ok {
+		if floatB, ok := toFloat(b); ok {
+			return floatA == floatB
+		}
+	}
+	return formatValue(a) == formatValue(b)
+}
+
+// Computes the changes turning the records of a into the records of b,
+// the records being matched on the values of the key columns
+// A field of only one of the records is a modification, from or to an empty value
+func diffRecords(a []gristapi.Record, b []gristapi.Record, keys []string) ([]recordChange, error) {
+	recordsB := map[string]gristapi.Record{}
+	for _, record := range b {
+		key, err := recordKey(record, keys)
+		if err != nil {
+			return nil, err
+		}
+		if _, found := recordsB[key]; found {
+			return nil, fmt.Errorf("duplicate key '%s', use --key to choose columns identifying the records", key)
+		}
+		recordsB[key] = record
+	}
+
+	changes := []recordChange{}
+	keysA := map[string]bool{}
+	for _, recordA := range a {
+		key, err := recordKey(recordA, keys)
+		if err != nil {
+			return nil, err
+		}
+		if keysA[key] {
+			return nil, fmt.Errorf("duplicate key '%s', use --key to choose columns identifying the records", key)
+		}
+		keysA[key] = true
+		recordB, found := recordsB[key]
+		if !found {
+			changes = append(changes, recordChange{Change: recordDeleted, Key: key, Record: recordA.Fields})
+			continue
+		}
+		fields := map[string]valueDiff{}
+		for field, valueA := range recordA.Fields {
+			if valueB, found := recordB.Fields[field]; !found || !sameValue(valueA, valueB) {
+				fields[field] = valueDiff{Old: valueA, New: valueB}
+			}
+		}
+		for field, valueB := range recordB.Fields {
+			if _, found := recordA.Fields[field]; !found {
+				fields[field] = valueDiff{Old: nil, New: valueB}
+			}
+		}
+		if len(fields) > 0 {
+			changes = append(changes, recordChange{Change: recordModified, Key: key, Fields: fields})
+		}
+	}
+	for _, recordB := range b {
+		key, _ := recordKey(recordB, keys)
+		if !keysA[key] {
+			changes = append(changes, recordChange{Change: recordInserted, Key: key, Record: recordB.Fields})
+		}
+	}
+	return changes, nil
+}
+
+// Returns the lines describing the changes of records: one line per modified field,
+// and one line per inserted or deleted record
+func recordChangesLines(changes []recordChange) [][]string {
+	lines := [][]string{}
+	for _, change := range changes {
+		switch change.Change {
+		case recordModified:
+			fields := []string{}
+			for field := range change.Fields {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			for _, field := range fields {
+				diff := change.Fields[field]
+				lines = append(lines, []string{change.Change, change.Key, field, formatValue(diff.Old), formatValue(diff.New)})
+			}
+		case recordDeleted:
+			lines = append(lines, []string{change.Change, change.Key, "", formatValue(change.Record), ""})
+		case recordInserted:
+			lines = append(lines, []string{change.Change, change.Key, "", "", formatValue(change.Record)})
+		}
+	}
+	return lines
+}
+
+/*
+Displays the differences between the records of two tables, each one being
+a table of a document (<doc id>/<table>) or a JSON or CSV file.
+Records are matched on the values of the key columns (their id by default).
+Returns ErrDifferent if the records are different
+*/
+func DiffData(nameA string, nameB string, keys []string) error {
+	if len(keys) == 0 {
+		keys = []string{"id"}
+	}
+	sourceA, err := parseDataSource(nameA)
+	if err != nil {
+		return err
+	}
+	sourceB, err := parseDataSource(nameB)
+	if err != nil {
+		return err
+	}
+
+	// The values of CSV files are converted with the types of the other table
+	typesA, err := sourceA.columnTypes()
+	if err != nil {
+		return err
+	}
+	typesB, err := sourceB.columnTypes()
+	if err != nil {
+		return err
+	}
+	if len(typesA) == 0 {
+		typesA = typesB
+	}
+	if len(typesB) == 0 {
+		typesB = typesA
+	}
+	recordsA, err := sourceA.records(typesA)
+	if err != nil {
+		return err
+	}
+	recordsB, err := sourceB.records(typesB)
+	if err != nil {
+		return err
+	}
+
+	changes, err := diffRecords(recordsA, recordsB, keys)
+	if err != nil {
+		return err
+	}
+
+	header := []string{"Change", strings.Join(keys, "|"), "Field", "Old", "New"}
+	switch output {
+	case "table":
+		{
+			if len(changes) == 0 {
+				fmt.Println("The records are the same ✅")
+				return nil
+			}
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader(header)
+			table.SetAutoFormatHeaders(false)
+			table.AppendBulk(recordChangesLines(changes))
+			table.Render()
+			counts := map[string]int{}
+			for _, change := range changes {
+				counts[change.Change]++
+			}
+			fmt.Printf("%d %s, %d %s, %d %s\n", counts[recordInserted], recordInserted, counts[recordDeleted], recordDeleted, counts[recordModified], recordModified)
+		}
+	case "json":
+		{
+			jsonChanges, err := json.MarshalIndent(changes, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(jsonChanges))
+		}
+	case "csv":
+		{
+			writer := csv.NewWriter(os.Stdout)
+			writer.Write(header)
+			writer.WriteAll(recordChangesLines(changes))
+			if err := writer.Error(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(changes) > 0 {
+		return ErrDifferent
+	}
+	return nil
+}
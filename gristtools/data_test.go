@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"encoding/json"
+	"fmt"
+	"gristctl/gristapi"
+	"slices"
+	"testing"
+)
+
+func TestSameValue(t *testing.T) {
+	tests := []struct {
+		a, b any
+		same bool
+	}{
+		{json.Number("1"), 1.0, true},
+		{json.Number("1.0"), 1, true},
+		{int64(1704153600), 1704153600.0, true},
+		{1.5, 1.0, false},
+		{"a", "a", true},
+		{"a", "b", false},
+		{nil, "", true},  // Empty cells of a CSV file
+		{"1", 1.0, true}, // Values of a CSV file without column types
+		{true, true, true},
+		{true, "false", false},
+		{[]any{"L", "a", "b"}, []any{"L", "a", "b"}, true},
+		{[]any{"L", "a", "b"}, []any{"L", "b", "a"}, false},
+	}
+	for _, test := range tests {
+		if same := sameValue(test.a, test.b); same != test.same {
+			t.Errorf("sameValue(%#v, %#v) = %v, expected %v", test.a, test.b, same, test.same)
+		}
+	}
+}
+
+func TestDiffRecords(t *testing.T) {
+	record := func(id int, name string, age any) gristapi.Record {
+		return gristapi.Record{Id: id, Fields: map[string]any{"Name": name, "Age": age}}
+	}
+	a := []gristapi.Record{record(1, "Alice", 30.0), record(2, "Bob", 40.0), record(3, "Carol", 50.0)}
+
+	tests := []struct {
+		name    string
+		b       []gristapi.Record
+		keys    []string
+		changes []string // change:key:fields
+		err     bool
+	}{
+		{"same records", []gristapi.Record{record(1, "Alice", json.Number("30")), record(2, "Bob", 40.0), record(3, "Carol", 50.0)}, []string{"id"}, []string{}, false},
+		{
+			"inserted, deleted and modified",
+			[]gristapi.Record{record(1, "Alice", 31.0), record(3, "Carol", 50.0), record(4, "Dave", 20.0)},
+			[]string{"id"},
+			[]string{"modified:1:[Age]", "deleted:2:[]", "inserted:4:[]"},
+			false,
+		},
+		{
+			"matched on another key",
+			[]gristapi.Record{record(10, "Alice", 30.0), record(20, "Bob", 41.0), record(30, "Carol", 50.0)},
+			[]string{"Name"},
+			[]string{"modified:Bob:[Age]"},
+			false,
+		},
+		{
+			"fields of one side only",
+			[]gristapi.Record{{Id: 1, Fields: map[string]any{"Name": "Alice"}}, {Id: 2, Fields: map[string]any{"Name": "Bob", "Age": 40.0, "City": "Paris"}}, record(3, "Carol", 50.0)},
+			[]string{"id"},
+			[]string{"modified:1:[Age]", "modified:2:[City]"},
+			false,
+		},
+		{"duplicate key", []gristapi.Record{record(1, "Alice", 30.0), record(2, "Alice", 40.0)}, []string{"Name"}, nil, true},
+		{"missing key column", a, []string{"City"}, nil, true},
+	}
+	for _, test := range tests {
+		changes, err := diffRecords(a, test.b, test.keys)
+		if (err != nil) != test.err {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if test.err {
+			continue
+		}
+		summary := []string{}
+		for _, change := range changes {
+			fields := []string{}
+			for field := range change.Fields {
+				fields = append(fields, field)
+			}
+			slices.Sort(fields)
+			summary = append(summary, fmt.Sprintf("%s:%s:%v", change.Change, change.Key, fields))
+		}
+		if !slices.Equal(summary, test.changes) {
+			t.Errorf("%s: unexpected changes %v, expected %v", test.name, summary, test.changes)
+		}
+	}
+
+	// A missing field is compared as an empty value
+	b := []gristapi.Record{{Id: 1, Fields: map[string]any{"Name": "Alice", "Age": 30.0, "City": "Paris"}}}
+	changes, _ := diffRecords([]gristapi.Record{{Id: 1, Fields: map[string]any{"Name": "Alice", "Age": 30.0}}}, b, []string{"id"})
+	if len(changes) != 1 || changes[0].Fields["City"] != (valueDiff{Old: nil, New: "Paris"}) {
+		t.Errorf("Unexpected changes %+v for a new column", changes)
+	}
+}
@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Number of unchanged lines displayed around the changes of a unified diff
+const diffContext = 3
+
+// Line of a diff: kept (' '), removed ('-') or added ('+')
+type diffLine struct {
+	Op   byte
+	Text string
+}
+
+// Computes the shortest list of line changes turning a into b (Myers algorithm)
+func lineDiff(a []string, b []string) []diffLine {
+	n, m := len(a), len(b)
+	total := n + m
+	offset := total + 1
+	v := make([]int, 2*total+3)
+	trace := [][]int{}
+
+	// Find the shortest edit path, saving the furthest points of each step
+	found := false
+	for d := 0; d <= total && !found; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	trace = append(trace, append([]int(nil), v...))
+
+	// Walk back the path, from the end
+	lines := []diffLine{}
+	x, y := n, m
+	for d := len(trace) - 2; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			lines = append(lines, diffLine{' ', a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				lines = append(lines, diffLine{'+', b[prevY]})
+			} else {
+				lines = append(lines, diffLine{'-', a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
+
+// Returns the differences between a and b in unified diff format
+// (empty if they are the same)
+func unifiedDiff(nameA string, nameB string, a []string, b []string) string {
+	lines := lineDiff(a, b)
+	var diff strings.Builder
+	// Position of each line in a and b
+	posA := make([]int, len(lines)+1)
+	posB := make([]int, len(lines)+1)
+	for i, line := range lines {
+		posA[i+1], posB[i+1] = posA[i], posB[i]
+		if line.Op != '+' {
+			posA[i+1]++
+		}
+		if line.Op != '-' {
+			posB[i+1]++
+		}
+	}
+
+	for start := 0; start < len(lines); {
+		// Next change
+		for start < len(lines) && lines[start].Op == ' ' {
+			start++
+		}
+		if start == len(lines) {
+			break
+		}
+		// The hunk goes on while the changes are separated by less than 2 contexts
+		end := start
+		for i := start; i < len(lines) && i-end <= 2*diffContext; i++ {
+			if lines[i].Op != ' ' {
+				end = i + 1
+			}
+		}
+		first := max(start-diffContext, 0)
+		last := min(end+diffContext, len(lines))
+
+		if diff.Len() == 0 {
+			fmt.Fprintf(&diff, "--- %s\n+++ %s\n", nameA, nameB)
+		}
+		fmt.Fprintf(&diff, "@@ -%s +%s @@\n", hunkRange(posA[first], posA[last]), hunkRange(posB[first], posB[last]))
+		for _, line := range lines[first:last] {
+			fmt.Fprintf(&diff, "%c%s\n", line.Op, line.Text)
+		}
+		start = last
+	}
+	return diff.String()
+}
+
+// Formats the range of lines of a hunk, from the position of its first and last lines
+func hunkRange(from int, to int) string {
+	switch to - from {
+	case 0:
+		return fmt.Sprintf("%d,0", from)
+	case 1:
+		return fmt.Sprintf("%d", from+1)
+	default:
+		return fmt.Sprintf("%d,%d", from+1, to-from)
+	}
+}
@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected string // Operations of the diff lines
+	}{
+		{"", "", ""},
+		{"a b c", "a b c", "   "},
+		{"", "a b", "++"},
+		{"a b", "", "--"},
+		{"a b c", "a c", " - "},
+		{"a c", "a b c", " + "},
+		{"a b c", "a x c", " -+ "},
+		{"a b c d", "b c d e", "-   +"},
+	}
+	for _, test := range tests {
+		a, b := strings.Fields(test.a), strings.Fields(test.b)
+		lines := lineDiff(a, b)
+		ops := ""
+		rebuiltA, rebuiltB := []string{}, []string{}
+		for _, line := range lines {
+			ops += string(line.Op)
+			if line.Op != '+' {
+				rebuiltA = append(rebuiltA, line.Text)
+			}
+			if line.Op != '-' {
+				rebuiltB = append(rebuiltB, line.Text)
+			}
+		}
+		if ops != test.expected {
+			t.Errorf("diff of %q and %q: unexpected operations %q, expected %q", test.a, test.b, ops, test.expected)
+		}
+		if strings.Join(rebuiltA, " ") != test.a || strings.Join(rebuiltB, " ") != test.b {
+			t.Errorf("diff of %q and %q: the lines do not give back the inputs (%v, %v)", test.a, test.b, rebuiltA, rebuiltB)
+		}
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	numbers := strings.Fields("1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20")
+	replace := func(lines []string, i int, text string) []string {
+		result := append([]string(nil), lines...)
+		result[i] = text
+		return result
+	}
+	tests := []struct {
+		name     string
+		a, b     []string
+		expected string
+	}{
+		{"same", numbers, numbers, ""},
+		{"one change", numbers[:5], replace(numbers[:5], 2, "x"), "--- a\n+++ b\n@@ -1,5 +1,5 @@\n 1\n 2\n-3\n+x\n 4\n 5\n"},
+		{"added line", []string{}, []string{"x"}, "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n"},
+		{
+			"two hunks",
+			numbers,
+			replace(replace(numbers, 1, "x"), 18, "y"),
+			"--- a\n+++ b\n@@ -1,5 +1,5 @@\n 1\n-2\n+x\n 3\n 4\n 5\n@@ -16,5 +16,5 @@\n 16\n 17\n 18\n-19\n+y\n 20\n",
+		},
+	}
+	for _, test := range tests {
+		if diff := unifiedDiff("a", "b", test.a, test.b); diff != test.expected {
+			t.Errorf("%s: unexpected diff\n%s\nexpected\n%s", test.name, diff, test.expected)
+		}
+	}
+}
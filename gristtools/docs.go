@@ -0,0 +1,177 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"encoding/json"
+	"fmt"
+	"gristctl/gristapi"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Creates an empty document in a workspace
+func CreateDoc(workspaceId int, name string) error {
+	docId, err := client.CreateDoc(ctx, workspaceId, name)
+	if err != nil {
+		return entityError("workspace", workspaceId, err)
+	}
+	fmt.Printf("Document '%s' created with id %s ✅\n", name, docId)
+	return nil
+}
+
+// Renames a document
+func RenameDoc(docId string, name string) error {
+	if err := client.RenameDoc(ctx, docId, name); err != nil {
+		return entityError("document", docId, err)
+	}
+	fmt.Printf("Document %s renamed to '%s' ✅\n", docId, name)
+	return nil
+}
+
+// Copies a document into each of the workspaces
+// The copies keep the name of the document, unless a name is given
+// With asTemplate, the data and history of the document are not copied
+func CopyDoc(docId string, workspaceIds []int, name string, asTemplate bool) error {
+	if name == "" {
+		doc, err := client.GetDoc(ctx, docId)
+		if err != nil {
+			return entityError("document", docId, err)
+		}
+		name = doc.Name
+	}
+
+	type docCopy struct {
+		WorkspaceId int    `json:"workspaceId"`
+		DocId       string `json:"docId"`
+		Name        string `json:"name"`
+	}
+	copies := []docCopy{}
+	var copyErr error
+	for _, workspaceId := range workspaceIds {
+		newDocId, err := client.CopyDoc(ctx, docId, workspaceId, name, asTemplate)
+		if err != nil {
+			copyErr = entityError("workspace", workspaceId, err)
+			break
+		}
+		copies = append(copies, docCopy{workspaceId, newDocId, name})
+	}
+
+	// The copies made before an error are displayed
+	switch output {
+	case "table":
+		{
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Workspace", "Document", "Name"})
+			for _, copy := range copies {
+				table.Append([]string{strconv.Itoa(copy.WorkspaceId), copy.DocId, copy.Name})
+			}
+			table.Render()
+		}
+	case "json":
+		{
+			jsonCopies, err := json.MarshalIndent(copies, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(jsonCopies))
+		}
+	}
+	return copyErr
+}
+
+// Moves a document to another workspace
+func MoveDoc(docId string, workspaceId int) error {
+	if err := client.MoveDoc(ctx, docId, workspaceId); err != nil {
+		return entityError("document", docId, err)
+	}
+	fmt.Printf("Document %s moved to workspace %d ✅\n", docId, workspaceId)
+	return nil
+}
+
+/*
+Imports a .grist, .xlsx or .csv file as a new document of a workspace
+
+If path is a directory, all the files of the directory with one of these
+extensions are imported, each document being named after its file.
+The ids of the new documents are displayed.
+*/
+func ImportDoc(path string, workspaceId int, name string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		if name != "" {
+			return fmt.Errorf("--name cannot be used when importing a directory")
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		files = []string{}
+		for _, entry := range entries {
+			if !entry.IsDir() && gristapi.IsImportable(entry.Name()) {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("no file to import in %s (allowed extensions: %s)", path, strings.Join(gristapi.ImportExtensions, ", "))
+		}
+	}
+
+	type importResult struct {
+		File  string `json:"file"`
+		DocId string `json:"docId,omitempty"`
+		Error string `json:"error,omitempty"`
+	}
+	results := []importResult{}
+	nbErrors := 0
+	for _, fileName := range files {
+		result := importResult{File: fileName}
+		file, err := os.Open(fileName)
+		if err == nil {
+			result.DocId, err = client.ImportDoc(ctx, workspaceId, fileName, file, name)
+			file.Close()
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			result.Error = entityError("workspace", workspaceId, err).Error()
+			nbErrors++
+		}
+		results = append(results, result)
+	}
+
+	switch output {
+	case "table":
+		{
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"File", "Document", "Error"})
+			for _, result := range results {
+				table.Append([]string{result.File, result.DocId, result.Error})
+			}
+			table.Render()
+		}
+	case "json":
+		{
+			jsonResults, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(jsonResults))
+		}
+	}
+	if nbErrors > 0 {
+		return fmt.Errorf("%d of %d files could not be imported", nbErrors, len(files))
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gristctl/gristapi"
+	"os"
+)
+
+// Exit codes of the program
+const (
+	ExitOK           = 0   // Success
+	ExitError        = 1   // Generic error
+	ExitDifferent    = 2   // The compared documents are different
+	ExitUnauthorized = 3   // The token was rejected by the server
+	ExitForbidden    = 4   // The user is not allowed to perform the request
+	ExitNotFound     = 5   // The requested entity does not exist
+	ExitCanceled     = 130 // The program was interrupted (Ctrl-C)
+)
+
+// Error returned by the diff commands when the documents are different
+var ErrDifferent = errors.New("the documents are different")
+
+// Returns the exit code matching an error
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, context.Canceled):
+		return ExitCanceled
+	case errors.Is(err, ErrDifferent):
+		return ExitDifferent
+	case gristapi.IsUnauthorized(err):
+		return ExitUnauthorized
+	case gristapi.IsForbidden(err):
+		return ExitForbidden
+	case gristapi.IsNotFound(err):
+		return ExitNotFound
+	default:
+		return ExitError
+	}
+}
+
+// Displays the error (if any) and exits with the matching exit code
+func ExitOnError(err error) {
+	if err == nil {
+		return
+	}
+	// The differences have already been displayed
+	if !errors.Is(err, ErrDifferent) {
+		fmt.Fprintf(os.Stderr, "❗️ %s ❗️\n", err)
+	}
+	os.Exit(ExitCode(err))
+}
+
+// Displays a warning when the token of the configuration file is used instead of
+// the token of the command or of the secret store, which is then not an error
+func WarnTokenFallback(err error) error {
+	if gristapi.IsTokenFallback(err) {
+		fmt.Fprintf(os.Stderr, "⚠️  %s\n", err)
+		return nil
+	}
+	return err
+}
+
+// Describes an error that occurred while querying an entity (document, workspace...)
+// with a meaningful message for the most common statuses
+func entityError(entity string, id any, err error) error {
+	switch {
+	case gristapi.IsNotFound(err):
+		return fmt.Errorf("%s %v not found: %w", entity, id, err)
+	case gristapi.IsForbidden(err):
+		return fmt.Errorf("access to %s %v is forbidden: %w", entity, id, err)
+	case gristapi.IsUnauthorized(err):
+		return fmt.Errorf("authentication refused, check your token with 'gristctl config': %w", err)
+	default:
+		return err
+	}
+}
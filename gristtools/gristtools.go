@@ -7,12 +7,14 @@ package gristtools
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"gristctl/common"
 	"gristctl/gristapi"
+	"io"
+	"net/http"
 	"os"
-	"regexp"
 	"slices"
 	"sort"
 	"strconv"
@@ -24,11 +26,23 @@ import (
 )
 
 var output string
+var client *gristapi.Client    // Client of the Grist server
+var ctx = context.Background() // Context of the requests sent to the server
 
 func SetOutput(out string) {
 	output = out
 }
 
+// Set the client used to query the Grist server
+func SetClient(c *gristapi.Client) {
+	client = c
+}
+
+// Set the context of the requests, to cancel them or limit their duration
+func SetContext(c context.Context) {
+	ctx = c
+}
+
 // Display help message and quit
 func Help() {
 
@@ -39,23 +53,68 @@ func Help() {
 	}
 
 	commands := []command{
+		{"[-o=json/table] backup --org <id|all> --dest <directory> [--concurrency <n>] [--incremental] [--keep-daily <n>] [--keep-weekly <n>]", common.T("help.backup")},
+		{"[-o=json/table] access set <org|workspace|doc> <id> <email> <role>", common.T("help.accessSet")},
+		{"[-o=json/table] access revoke <org|workspace|doc> <id> <email>", common.T("help.accessRevoke")},
+		{"[-o=json/table] access set-inheritance <workspace|doc> <id> <owners|editors|viewers|none>", common.T("help.accessInheritance")},
+		{"[-o=json/table] access plan -f <file> [--prune]", common.T("help.accessPlan")},
+		{"[-o=json/table] access apply -f <file> [--prune] [--yes]", common.T("help.accessApply")},
 		{"config", common.T("help.config")},
-		{"delete doc <id>", common.T("help.deleteDoc")},
+		{"config add <profile>", common.T("help.configAdd")},
+		{"[-o=json/table] config list", common.T("help.configList")},
+		{"config remove <profile>", common.T("help.configRemove")},
+		{"config use <profile>", common.T("help.configUse")},
+		{"add column <doc id> <table> -f <file>", common.T("help.columnAdd")},
+		{"add records <doc id> <table> [-f <file>]", common.T("help.recordsAdd")},
+		{"[-o=json/table] copy doc <id> <workspace id>... [--name <name>] [--template]", common.T("help.docCopy")},
+		{"create doc <workspace id> <name>", common.T("help.docCreate")},
+		{"create table <doc id> -f <file>", common.T("help.tableCreate")},
+		{"delete column <doc id> <table> <column>", common.T("help.columnDelete")},
+		{"create workspace <org id> <name>", common.T("help.workspaceCreate")},
+		{"[-o=json/table] create workspaces --org <id> [-f <file>]", common.T("help.workspacesCreate")},
+		{"rename workspace <id> <name>", common.T("help.workspaceRename")},
+		{"delete doc <id> [--permanent]", common.T("help.deleteDoc")},
+		{"delete records <doc id> <table> [<record id>...] [-f <file>] [--yes]", common.T("help.recordsDelete")},
 		{"delete user <id>", common.T("help.deleteUser")},
-		{"delete workspace <id>", common.T("help.deleteWorkspace")},
+		{"delete workspace <id> [--permanent]", common.T("help.deleteWorkspace")},
 		{"[-o=json/table] get doc <id> access", common.T("help.docAccess")},
-		{"get doc <id> excel", common.T("help.docExportExcel")},
-		{"get doc <id> grist", common.T("help.docExportGrist")},
+		{"get doc <id> excel [-f <file>|-]", common.T("help.docExportExcel")},
+		{"get doc <id> grist [-f <file>|-]", common.T("help.docExportGrist")},
 		{"get doc <id> table <tableName>", common.T("help.docExportCsv")},
+		{"[-o=json/table] get doc <id> states", common.T("help.docStates")},
+		{"[-o=json/table] get doc <id> snapshots [--sizes]", common.T("help.docSnapshots")},
 		{"[-o=json/table] get doc <id>", common.T("help.docDesc")},
 		{"[-o=json/table] get org <id>", common.T("help.orgDesc")},
+		{"[-o=json/table] get records <doc id> <table> [--filter <json>] [--sort <columns>] [--limit <n>]", common.T("help.recordsList")},
 		{"[-o=json/table] get org", common.T("help.orgList")},
 		{"[-o=json/table] get user <id>", common.T("help.userDesc")},
 		{"[-o=json/table] get user", common.T("help.userList")},
 		{"[-o=json/table] get workspace <id> access", common.T("help.workspaceAccess")},
 		{"[-o=json/table] get workspace <id>", common.T("help.workspaceDesc")},
+		{"[-o=json/table/unified] diff schema <doc id> <doc id>", common.T("help.diffSchema")},
+		{"[-o=json/table/csv] diff data <doc id>/<table> <doc id>/<table> [--key <columns>]", common.T("help.diffData")},
+		{"[-o=json/table] migrate --to <profile> --workspace <id> [--from <profile>] [--org <id>] [--state <file>]", common.T("help.migrate")},
+		{"restore doc <id>", common.T("help.restoreTrashDoc")},
+		{"restore workspace <id>", common.T("help.restoreTrashWorkspace")},
+		{"[-o=json/table] get trash --org <id>", common.T("help.trash")},
+		{"restore <file.grist> --workspace <id> [--name <name>]", common.T("help.restoreDoc")},
+		{"restore <file.grist> --doc <id> [--yes]", common.T("help.restoreReplace")},
+		{"[-o=json/table] restore --manifest <file or directory> [--org <id>] [--dry-run]", common.T("help.restoreBackup")},
+		{"[-o=json/table] import doc <file or directory> --workspace <id> [--name <name>]", common.T("help.docImport")},
 		{"import users", common.T("help.userImport")},
-		{"purge doc <id> [<number of states to keep>]", common.T("help.docPurge")},
+		{"modify column <doc id> <table> <column> [--type <type>] [--label <label>] [--formula <formula>]", common.T("help.columnModify")},
+		{"modify column <doc id> <table> -f <file>", common.T("help.columnModifyFile")},
+		{"move doc <id> <workspace id>", common.T("help.docMove")},
+		{"[-o=json/table] purge doc <id> [<number of states to keep>] [--yes] [--no-sizes]", common.T("help.docPurge")},
+		{"[-o=json/table] purge workspace <id> [<number of states to keep>] [--yes] [--concurrency <n>] [--no-sizes]", common.T("help.workspacePurge")},
+		{"[-o=json/table] purge org <id> [<number of states to keep>] [--yes] [--concurrency <n>] [--no-sizes]", common.T("help.orgPurge")},
+		{"rename doc <id> <name>", common.T("help.docRename")},
+		{"[-o=json] schema export <doc id> [-f <file>]", common.T("help.schemaExport")},
+		{"[-o=json/table] schema apply <doc id> -f <file> [--dry-run] [--prune] [--yes]", common.T("help.schemaApply")},
+		{"[-o=json/table] sql <doc id> \"<query>\" [<parameter>...]", common.T("help.sqlQuery")},
+		{"[-o=json/table] sql <doc id> -f <file> [<parameter>...]", common.T("help.sqlQueryFile")},
+		{"update records <doc id> <table> [-f <file>]", common.T("help.recordsUpdate")},
+		{"upsert records <doc id> <table> --key <columns> [-f <file>]", common.T("help.recordsUpsert")},
 		{"version", common.T("help.version")},
 	}
 	// Sort commands by name
@@ -78,59 +137,6 @@ func Version(version string) {
 	fmt.Println("Version : ", version)
 }
 
-/*
-Configure Grist envfile (url and api token)
-Interactive filling the `.gristctl` file
-*/
-func Config() {
-	configFile := gristapi.GetConfig()
-	common.DisplayTitle(fmt.Sprintf("%s (%s)", common.T("config.title"), configFile))
-	fmt.Printf("%s :\n- URL : %s\n", common.T("config.actual"), os.Getenv("GRIST_URL"))
-	token := ""
-	for i := 0; i < len(os.Getenv("GRIST_TOKEN")); i++ {
-		token += "•"
-	}
-	fmt.Printf("- %s : %s\n", common.T("config.token"), token)
-	testConnect := "❌"
-	if gristapi.TestConnection() {
-		testConnect = "✅"
-	}
-	fmt.Printf("%s : %s\n", common.T("config.connectTest"), testConnect)
-
-	if common.Confirm(common.T("config.config")) {
-		var url string
-		urlSet := false
-		for urlSet == false {
-			url = common.Ask(common.T("config.urlSet"))
-
-			// Test if url is well formatted
-			urlOk, _ := regexp.MatchString(`^https?://.*[^/]$`, url)
-			urlSet = urlOk
-		}
-		var token = common.Ask(common.T("config.token"))
-		if common.Confirm(fmt.Sprintf("\n%s :\n- URL : %s\n- Token: %s\n%s ", common.T("config.new"), url, token, common.T("questions.isOk"))) {
-			f, err := os.Create(configFile)
-			if err != nil {
-				fmt.Printf("%s %s (%s)", common.T("config.saveError"), configFile, err)
-				os.Exit(-1)
-			}
-			defer f.Close()
-			config := fmt.Sprintf("GRIST_URL=\"%s\"\nGRIST_TOKEN=\"%s\"\n", url, token)
-			f.WriteString(config)
-			f.Close()
-			fmt.Printf("%s %s\n", common.T("config.savedIn"), configFile)
-
-			// Test the configuration by connecting to the server
-			nbOrgs := len(gristapi.GetOrgs())
-			fmt.Printf("Nb orgs : %d\n", nbOrgs)
-			if nbOrgs <= 0 {
-				fmt.Println(common.T("config.connectError"))
-				os.Exit(-1)
-			}
-		}
-	}
-}
-
 /*
 User role translation
 
@@ -233,14 +239,27 @@ func ImportUsers() {
 				roles = append(roles, newRole)
 			}
 		}
-		gristapi.ImportUsers(orgId, workspaceId, roles)
+		idWorkspace, created, err := client.ImportUsers(ctx, orgId, workspaceId, roles)
+		if created {
+			fmt.Printf("Workspace '%s' created with id %d\n", workspaceId, idWorkspace)
+		}
+		var result string
+		if err == nil {
+			result = "✅"
+		} else {
+			result = fmt.Sprintf("❗️ (%s)", err)
+		}
+		fmt.Printf("Import %d users in workspace '%s'\t : %s\n", len(roles), workspaceId, result)
 	}
 }
 
 // Displays the list of users witch access to an organization
-func DisplayOrgAccess(idOrg string) {
+func DisplayOrgAccess(idOrg string) error {
 
-	lstUsers := gristapi.GetOrgAccess(idOrg)
+	lstUsers, err := client.GetOrgAccess(ctx, idOrg)
+	if err != nil {
+		return entityError("organization", idOrg, err)
+	}
 
 	switch output {
 	case "table":
@@ -262,6 +281,7 @@ func DisplayOrgAccess(idOrg string) {
 			fmt.Println(string(jsonUsers))
 		}
 	}
+	return nil
 }
 
 /*
@@ -274,7 +294,7 @@ func DisplayOrgAccess(idOrg string) {
   - Number of rows
   - List of columns
 */
-func DisplayDoc(docId string) {
+func DisplayDoc(docId string) error {
 	type TableDetails struct {
 		Name       string
 		Nb_rows    int
@@ -291,95 +311,122 @@ func DisplayDoc(docId string) {
 	}
 
 	// Getting the document
-	doc := gristapi.GetDoc(docId)
-	if doc.Id == "" {
-		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
-	} else {
-		// Document was found
-		// Getting the doc's tables
-		var tables gristapi.Tables = gristapi.GetDocTables(docId)
-
-		myDoc := DocInfo{
-			Id:       doc.Id,
-			Name:     doc.Name,
-			IsPinned: doc.IsPinned,
-			NbTables: len(tables.Tables),
-		}
-
-		// Getting the tables details
-		var wg sync.WaitGroup
-		var tables_details []TableDetails
-		for _, table := range tables.Tables {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				table_desc := ""
-				columns := gristapi.GetTableColumns(docId, table.Id)
-				rows := gristapi.GetTableRows(docId, table.Id)
-
-				var cols_names []string
-				for _, col := range columns.Columns {
-					cols_names = append(cols_names, col.Id)
-				}
-				slices.Sort(cols_names)
-				for _, col := range cols_names {
-					table_desc += fmt.Sprintf("%s ", col)
-				}
-				table_info := TableDetails{
-					Name:       table.Id,
-					Nb_rows:    len(rows.Id),
-					Nb_cols:    len(columns.Columns),
-					Cols_names: cols_names,
-				}
-				tables_details = append(tables_details, table_info)
-			}()
-		}
-		wg.Wait()
+	doc, err := client.GetDoc(ctx, docId)
+	if err != nil {
+		return entityError("document", docId, err)
+	}
+	// Document was found
+	// Getting the doc's tables
+	tables, err := client.GetDocTables(ctx, docId)
+	if err != nil {
+		return err
+	}
 
-		myDoc.Tables = tables_details
+	myDoc := DocInfo{
+		Id:       doc.Id,
+		Name:     doc.Name,
+		IsPinned: doc.IsPinned,
+		NbTables: len(tables.Tables),
+	}
 
-		switch output {
-		case "json":
-			{
-				jsonDoc, err := json.MarshalIndent(myDoc, "", "   ")
-				if err != nil {
-					fmt.Println(err)
-				}
-				fmt.Println(string(jsonDoc))
-			}
-		case "table":
-			{
-				// Displaying the document name
-				pinned := ""
-				if myDoc.IsPinned {
-					pinned = "📌"
+	// Getting the tables details
+	// The first error cancels the requests of the other tables
+	tablesCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var tables_details []TableDetails
+	var firstErr error
+	for _, table := range tables.Tables {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			table_desc := ""
+			columns, err := client.GetTableColumns(tablesCtx, docId, table.Id)
+			var rows gristapi.TableRows
+			if err == nil {
+				rows, err = client.GetTableRows(tablesCtx, docId, table.Id)
+			}
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+					cancel()
 				}
-				common.DisplayTitle(fmt.Sprintf("Document '%s' (%s) %s", myDoc.Name, myDoc.Id, pinned))
-				fmt.Printf("Contains %d tables :\n", myDoc.NbTables)
-				// Displaying the tables details
-				tableView := tablewriter.NewWriter(os.Stdout)
-				tableView.SetHeader([]string{"Table", common.T("col.nbCols"), common.T("col.columns"), common.T("col.nbRows")})
-				for _, table_details := range tables_details {
-					for i, col_name := range table_details.Cols_names {
-						if i == 0 {
-							tableView.Append([]string{table_details.Name, strconv.Itoa(table_details.Nb_cols), col_name, strconv.Itoa(table_details.Nb_rows)})
-						} else {
-							tableView.Append([]string{"", "", col_name, ""})
-						}
+				mu.Unlock()
+				return
+			}
+
+			var cols_names []string
+			for _, col := range columns.Columns {
+				cols_names = append(cols_names, col.Id)
+			}
+			slices.Sort(cols_names)
+			for _, col := range cols_names {
+				table_desc += fmt.Sprintf("%s ", col)
+			}
+			table_info := TableDetails{
+				Name:       table.Id,
+				Nb_rows:    len(rows.Id),
+				Nb_cols:    len(columns.Columns),
+				Cols_names: cols_names,
+			}
+			mu.Lock()
+			tables_details = append(tables_details, table_info)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	myDoc.Tables = tables_details
+
+	switch output {
+	case "json":
+		{
+			jsonDoc, err := json.MarshalIndent(myDoc, "", "   ")
+			if err != nil {
+				fmt.Println(err)
+			}
+			fmt.Println(string(jsonDoc))
+		}
+	case "table":
+		{
+			// Displaying the document name
+			pinned := ""
+			if myDoc.IsPinned {
+				pinned = "📌"
+			}
+			common.DisplayTitle(fmt.Sprintf("Document '%s' (%s) %s", myDoc.Name, myDoc.Id, pinned))
+			fmt.Printf("Contains %d tables :\n", myDoc.NbTables)
+			// Displaying the tables details
+			tableView := tablewriter.NewWriter(os.Stdout)
+			tableView.SetHeader([]string{"Table", common.T("col.nbCols"), common.T("col.columns"), common.T("col.nbRows")})
+			for _, table_details := range tables_details {
+				for i, col_name := range table_details.Cols_names {
+					if i == 0 {
+						tableView.Append([]string{table_details.Name, strconv.Itoa(table_details.Nb_cols), col_name, strconv.Itoa(table_details.Nb_rows)})
+					} else {
+						tableView.Append([]string{"", "", col_name, ""})
 					}
 				}
-				tableView.Render()
 			}
+			tableView.Render()
 		}
 	}
-
+	return nil
 }
 
 // Displays the list of accessible organizations
-func DisplayOrgs() {
+func DisplayOrgs() error {
 
 	// Getting the list of organizations
-	lstOrgs := gristapi.GetOrgs()
+	lstOrgs, err := client.GetOrgs(ctx)
+	if err != nil {
+		return err
+	}
 	// Sorting the list of organizations by name (lowercase)
 	sort.Slice(lstOrgs, func(i, j int) bool {
 		return strings.ToLower(lstOrgs[i].Name) < strings.ToLower(lstOrgs[j].Name)
@@ -404,11 +451,15 @@ func DisplayOrgs() {
 			fmt.Println(string(jsonOrgs))
 		}
 	}
+	return nil
 }
 
 // Displays details about a specific user
-func DisplayUser(userId int) {
-	user := gristapi.GetUser(userId)
+func DisplayUser(userId int) error {
+	user, err := client.GetUser(ctx, userId)
+	if err != nil {
+		return entityError("user", userId, err)
+	}
 
 	switch output {
 	case "table":
@@ -432,12 +483,16 @@ func DisplayUser(userId int) {
 			}
 		}
 	}
+	return nil
 }
 
 // Displays the list of users with access to the Grist instance
-func DisplayUsers() {
+func DisplayUsers() error {
 	// Getting the list of users
-	lstUsers := gristapi.GetUsers()
+	lstUsers, err := client.GetUsers(ctx)
+	if err != nil {
+		return err
+	}
 	fmt.Println("Nb users :", len(lstUsers))
 	// Sorting the list of users by email (lowercase)
 	// sort.Slice(lstUsers, func(i, j int) bool {
@@ -464,10 +519,11 @@ func DisplayUsers() {
 	// 		}
 	// 	}
 	// }
+	return nil
 }
 
 // Displays details about an organization
-func DisplayOrg(orgId string) {
+func DisplayOrg(orgId string) error {
 
 	type WpDesc struct {
 		Id     int    `json:"id"`
@@ -485,68 +541,78 @@ func DisplayOrg(orgId string) {
 
 	var lstWsDesc []WpDesc
 
-	org := gristapi.GetOrg(orgId)
-	if org.Id == 0 {
-		fmt.Printf("❗️ Organization %s not found ❗️\n", orgId)
-	} else {
-
-		// Org was found
-		worskspaces := gristapi.GetOrgWorkspaces(org.Id)
-		var wg sync.WaitGroup
-		// Retrieving the number of documents and users for each workspace
-		for _, ws := range worskspaces {
-			func() {
-				defer wg.Done()
-				wg.Add(1)
-				users := gristapi.GetWorkspaceAccess(ws.Id)
-				nbUsers := 0
-				for _, user := range users.Users {
-					if user.Access != "" {
-						nbUsers += 1
-					}
-				}
-				lstWsDesc = append(lstWsDesc, WpDesc{ws.Id, ws.Name, len(ws.Docs), nbUsers})
-			}()
-		}
-		wg.Wait()
-		// Sorting the list of workspaces by name
-		sort.Slice(lstWsDesc, func(i, j int) bool {
-			return lstWsDesc[i].Name < lstWsDesc[j].Name
-		})
-		switch output {
-		case "table":
-			{
-				common.DisplayTitle(fmt.Sprintf("%s n°%d : %s", common.T("org.name"), org.Id, org.Name))
-				fmt.Printf("%s %d:\n", common.T("org.contains"), len(worskspaces))
-				table := tablewriter.NewWriter(os.Stdout)
-				table.SetHeader([]string{common.T("col.ident"), common.T("col.name"), common.T("col.nbDocs"), common.T("col.directUsers")})
-				// Displaying the list of workspaces
-				for _, desc := range lstWsDesc {
-					table.Append([]string{strconv.Itoa(desc.Id), desc.Name, strconv.Itoa(desc.NbDoc), strconv.Itoa(desc.NbUser)})
-				}
-				table.Render()
+	org, err := client.GetOrg(ctx, orgId)
+	if err != nil {
+		return entityError("organization", orgId, err)
+	}
+
+	// Org was found
+	worskspaces, err := client.GetOrgWorkspaces(ctx, org.Id)
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	// Retrieving the number of documents and users for each workspace
+	for _, ws := range worskspaces {
+		err = func() error {
+			defer wg.Done()
+			wg.Add(1)
+			users, err := client.GetWorkspaceAccess(ctx, ws.Id)
+			if err != nil {
+				return err
 			}
-		case "json":
-			{
-				myOrg := OrgDesc{
-					Id:   org.Id,
-					Name: org.Name,
-					NbWs: len(worskspaces),
-					Ws:   lstWsDesc,
+			nbUsers := 0
+			for _, user := range users.Users {
+				if user.Access != "" {
+					nbUsers += 1
 				}
+			}
+			lstWsDesc = append(lstWsDesc, WpDesc{ws.Id, ws.Name, len(ws.Docs), nbUsers})
+			return nil
+		}()
+		if err != nil {
+			return err
+		}
+	}
+	wg.Wait()
+	// Sorting the list of workspaces by name
+	sort.Slice(lstWsDesc, func(i, j int) bool {
+		return lstWsDesc[i].Name < lstWsDesc[j].Name
+	})
+	switch output {
+	case "table":
+		{
+			common.DisplayTitle(fmt.Sprintf("%s n°%d : %s", common.T("org.name"), org.Id, org.Name))
+			fmt.Printf("%s %d:\n", common.T("org.contains"), len(worskspaces))
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{common.T("col.ident"), common.T("col.name"), common.T("col.nbDocs"), common.T("col.directUsers")})
+			// Displaying the list of workspaces
+			for _, desc := range lstWsDesc {
+				table.Append([]string{strconv.Itoa(desc.Id), desc.Name, strconv.Itoa(desc.NbDoc), strconv.Itoa(desc.NbUser)})
+			}
+			table.Render()
+		}
+	case "json":
+		{
+			myOrg := OrgDesc{
+				Id:   org.Id,
+				Name: org.Name,
+				NbWs: len(worskspaces),
+				Ws:   lstWsDesc,
+			}
 
-				jsonData, err := json.MarshalIndent(myOrg, "", "  ")
-				if err != nil {
-					fmt.Println(err)
-				}
-				fmt.Println(string(jsonData))
+			jsonData, err := json.MarshalIndent(myOrg, "", "  ")
+			if err != nil {
+				fmt.Println(err)
 			}
+			fmt.Println(string(jsonData))
 		}
 	}
+	return nil
 }
 
 // Display a Workspace
-func DisplayWorkspace(workspaceId int) {
+func DisplayWorkspace(workspaceId int) error {
 
 	type docDesc struct {
 		Id       string `json:"id"`
@@ -564,72 +630,72 @@ func DisplayWorkspace(workspaceId int) {
 	}
 
 	// Getting the workspace
-	ws := gristapi.GetWorkspace(workspaceId)
-	if ws.Id == 0 {
-		fmt.Printf("❗️ Workspace %d not found ❗️\n", workspaceId)
-	} else {
-		// Workspace was found
-
-		myDocs := []docDesc{}
-		for _, doc := range ws.Docs {
-			myDocs = append(myDocs, docDesc{doc.Id, doc.Name, doc.IsPinned})
-		}
-
-		// Sort the documents by name (lowercase)
-		sort.Slice(myDocs, func(i, j int) bool {
-			return strings.ToLower(myDocs[i].Name) < strings.ToLower(myDocs[j].Name)
-		})
-
-		myWS := WorkspaceDesc{
-			OrgId:   ws.Org.Id,
-			OrgName: ws.Org.Name,
-			Id:      ws.Id,
-			Name:    ws.Name,
-			NbDocs:  len(ws.Docs),
-			Docs:    myDocs,
-		}
-
-		switch output {
-		case "table":
-			{
-				common.DisplayTitle(fmt.Sprintf("%s n°%d : '%s' | %s n°%d : '%s'",
-					common.T("org.name"),
-					myWS.OrgId,
-					myWS.OrgName,
-					common.T("workspace.name"),
-					myWS.Id,
-					myWS.Name))
-				fmt.Printf("Contains %d documents :\n", myWS.NbDocs)
-				// Listing the documents
-				if myWS.NbDocs > 0 {
-					table := tablewriter.NewWriter(os.Stdout)
-					table.SetHeader([]string{common.T("col.ident"), common.T("col.name"), common.T("col.pinned")})
-					for _, doc := range myWS.Docs {
-						pin := ""
-						if doc.IsPinned {
-							pin = "📌"
-						}
-						table.Append([]string{doc.Id, doc.Name, pin})
+	ws, err := client.GetWorkspace(ctx, workspaceId)
+	if err != nil {
+		return entityError("workspace", workspaceId, err)
+	}
+	// Workspace was found
+
+	myDocs := []docDesc{}
+	for _, doc := range ws.Docs {
+		myDocs = append(myDocs, docDesc{doc.Id, doc.Name, doc.IsPinned})
+	}
+
+	// Sort the documents by name (lowercase)
+	sort.Slice(myDocs, func(i, j int) bool {
+		return strings.ToLower(myDocs[i].Name) < strings.ToLower(myDocs[j].Name)
+	})
+
+	myWS := WorkspaceDesc{
+		OrgId:   ws.Org.Id,
+		OrgName: ws.Org.Name,
+		Id:      ws.Id,
+		Name:    ws.Name,
+		NbDocs:  len(ws.Docs),
+		Docs:    myDocs,
+	}
+
+	switch output {
+	case "table":
+		{
+			common.DisplayTitle(fmt.Sprintf("%s n°%d : '%s' | %s n°%d : '%s'",
+				common.T("org.name"),
+				myWS.OrgId,
+				myWS.OrgName,
+				common.T("workspace.name"),
+				myWS.Id,
+				myWS.Name))
+			fmt.Printf("Contains %d documents :\n", myWS.NbDocs)
+			// Listing the documents
+			if myWS.NbDocs > 0 {
+				table := tablewriter.NewWriter(os.Stdout)
+				table.SetHeader([]string{common.T("col.ident"), common.T("col.name"), common.T("col.pinned")})
+				for _, doc := range myWS.Docs {
+					pin := ""
+					if doc.IsPinned {
+						pin = "📌"
 					}
-					table.Render()
-				} else {
-					fmt.Println("No documents")
+					table.Append([]string{doc.Id, doc.Name, pin})
 				}
+				table.Render()
+			} else {
+				fmt.Println("No documents")
 			}
-		case "json":
-			{
-				jsonData, err := json.MarshalIndent(myWS, "", "  ")
-				if err != nil {
-					fmt.Println(err)
-				}
-				fmt.Println(string(jsonData))
+		}
+	case "json":
+		{
+			jsonData, err := json.MarshalIndent(myWS, "", "  ")
+			if err != nil {
+				fmt.Println(err)
 			}
+			fmt.Println(string(jsonData))
 		}
 	}
+	return nil
 }
 
 // Displays workspace access rights
-func DisplayWorkspaceAccess(workspaceId int) {
+func DisplayWorkspaceAccess(workspaceId int) error {
 	type wsUser struct {
 		Id           int    `json:"id"`
 		Email        string `json:"email"`
@@ -649,77 +715,80 @@ func DisplayWorkspaceAccess(workspaceId int) {
 	}
 
 	// Getting the workspace
-	ws := gristapi.GetWorkspace((workspaceId))
-	if ws.Id == 0 {
-		fmt.Printf("❗️ Workspace %d not found ❗️\n", workspaceId)
-	} else {
-		// Workspace was found
-		wsa := gristapi.GetWorkspaceAccess(workspaceId)
-
-		var myUsers []wsUser
-		nbUsers := 0
-		for _, user := range wsa.Users {
-			if user.Access != "" || user.ParentAccess != "" {
-				tmpUser := wsUser{
-					Id:           user.Id,
-					Email:        user.Email,
-					Name:         user.Name,
-					ParentAccess: user.ParentAccess,
-					Access:       user.Access,
-				}
-				myUsers = append(myUsers, tmpUser)
-				nbUsers++
-			}
-		}
-		// Sort users by email (lowercase)
-		sort.Slice(myUsers, func(i, j int) bool {
-			return strings.ToLower(myUsers[i].Email) < strings.ToLower(myUsers[j].Email)
-		})
-		myWsAccess := wsAccess{
-			WokspaceId:       ws.Id,
-			WorkspaceName:    ws.Name,
-			OrgId:            ws.Org.Id,
-			OrgName:          ws.Org.Name,
-			MaxInheritedRole: wsa.MaxInheritedRole,
-			NbUsers:          nbUsers,
-			Users:            myUsers,
-		}
-
-		switch output {
-		case "table":
-			{
-				// Displaying the workspace name
-				common.DisplayTitle(fmt.Sprintf("Workspace n°%d : %s", myWsAccess.WokspaceId, myWsAccess.WorkspaceName))
-
-				// Displaying the MaxInheritedRole
-				fmt.Println(TranslateRole(myWsAccess.MaxInheritedRole))
-
-				if myWsAccess.NbUsers <= 0 {
-					fmt.Println("Accessible to no user")
-				} else {
-					fmt.Printf("\nAccessible to %d users :\n", myWsAccess.NbUsers)
-					table := tablewriter.NewWriter(os.Stdout)
-					table.SetHeader([]string{"Id", "Nom", "Email", "Inherited access", "Direct access"})
-					for _, user := range myWsAccess.Users {
-						table.Append([]string{strconv.Itoa(user.Id), user.Name, user.Email, user.ParentAccess, user.Access})
-					}
-					table.Render()
-				}
+	ws, err := client.GetWorkspace(ctx, workspaceId)
+	if err != nil {
+		return entityError("workspace", workspaceId, err)
+	}
+	// Workspace was found
+	wsa, err := client.GetWorkspaceAccess(ctx, workspaceId)
+	if err != nil {
+		return err
+	}
+
+	var myUsers []wsUser
+	nbUsers := 0
+	for _, user := range wsa.Users {
+		if user.Access != "" || user.ParentAccess != "" {
+			tmpUser := wsUser{
+				Id:           user.Id,
+				Email:        user.Email,
+				Name:         user.Name,
+				ParentAccess: user.ParentAccess,
+				Access:       user.Access,
 			}
-		case "json":
-			{
-				jsonAccess, err := json.MarshalIndent(myWsAccess, "", "   ")
-				if err != nil {
-					fmt.Println(err)
+			myUsers = append(myUsers, tmpUser)
+			nbUsers++
+		}
+	}
+	// Sort users by email (lowercase)
+	sort.Slice(myUsers, func(i, j int) bool {
+		return strings.ToLower(myUsers[i].Email) < strings.ToLower(myUsers[j].Email)
+	})
+	myWsAccess := wsAccess{
+		WokspaceId:       ws.Id,
+		WorkspaceName:    ws.Name,
+		OrgId:            ws.Org.Id,
+		OrgName:          ws.Org.Name,
+		MaxInheritedRole: wsa.MaxInheritedRole,
+		NbUsers:          nbUsers,
+		Users:            myUsers,
+	}
+
+	switch output {
+	case "table":
+		{
+			// Displaying the workspace name
+			common.DisplayTitle(fmt.Sprintf("Workspace n°%d : %s", myWsAccess.WokspaceId, myWsAccess.WorkspaceName))
+
+			// Displaying the MaxInheritedRole
+			fmt.Println(TranslateRole(myWsAccess.MaxInheritedRole))
+
+			if myWsAccess.NbUsers <= 0 {
+				fmt.Println("Accessible to no user")
+			} else {
+				fmt.Printf("\nAccessible to %d users :\n", myWsAccess.NbUsers)
+				table := tablewriter.NewWriter(os.Stdout)
+				table.SetHeader([]string{"Id", "Nom", "Email", "Inherited access", "Direct access"})
+				for _, user := range myWsAccess.Users {
+					table.Append([]string{strconv.Itoa(user.Id), user.Name, user.Email, user.ParentAccess, user.Access})
 				}
-				fmt.Println(string(jsonAccess))
+				table.Render()
+			}
+		}
+	case "json":
+		{
+			jsonAccess, err := json.MarshalIndent(myWsAccess, "", "   ")
+			if err != nil {
+				fmt.Println(err)
 			}
+			fmt.Println(string(jsonAccess))
 		}
 	}
+	return nil
 }
 
 // Displays users with access to a document
-func DisplayDocAccess(docId string) {
+func DisplayDocAccess(docId string) error {
 	type UserAccess struct {
 		UserId       string `json:"userId"`
 		UserEmail    string `json:"userEmail"`
@@ -736,69 +805,72 @@ func DisplayDocAccess(docId string) {
 	var myDocAccess DocAcces
 
 	// Getting the document
-	doc := gristapi.GetDoc(docId)
-	if doc.Name == "" {
-		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
-	} else {
-		// Document was found
-		// Displaying the access rights
-		docAccess := gristapi.GetDocAccess(docId)
-		// Sorting users by email (lowercase)
-		sort.Slice(docAccess.Users, func(i, j int) bool {
-			return strings.ToLower(docAccess.Users[i].Email) < strings.ToLower(docAccess.Users[j].Email)
-		})
-		var tmpUsers []UserAccess
-		for _, user := range docAccess.Users {
-			if user.Access != "" {
-				userAccess := UserAccess{
-					UserId:       strconv.Itoa(user.Id),
-					UserEmail:    user.Email,
-					ParentAccess: user.ParentAccess,
-					Access:       user.Access,
-				}
-
-				tmpUsers = append(tmpUsers, userAccess)
+	doc, err := client.GetDoc(ctx, docId)
+	if err != nil {
+		return entityError("document", docId, err)
+	}
+	// Document was found
+	// Displaying the access rights
+	docAccess, err := client.GetDocAccess(ctx, docId)
+	if err != nil {
+		return err
+	}
+	// Sorting users by email (lowercase)
+	sort.Slice(docAccess.Users, func(i, j int) bool {
+		return strings.ToLower(docAccess.Users[i].Email) < strings.ToLower(docAccess.Users[j].Email)
+	})
+	var tmpUsers []UserAccess
+	for _, user := range docAccess.Users {
+		if user.Access != "" {
+			userAccess := UserAccess{
+				UserId:       strconv.Itoa(user.Id),
+				UserEmail:    user.Email,
+				ParentAccess: user.ParentAccess,
+				Access:       user.Access,
 			}
 
-			myDocAccess = DocAcces{
-				DocId:            doc.Id,
-				DocName:          doc.Name,
-				WorkspaceName:    doc.Workspace.Name,
-				MaxInheritedRole: TranslateRole(docAccess.MaxInheritedRole),
-				UserAccess:       tmpUsers,
-			}
+			tmpUsers = append(tmpUsers, userAccess)
+		}
 
+		myDocAccess = DocAcces{
+			DocId:            doc.Id,
+			DocName:          doc.Name,
+			WorkspaceName:    doc.Workspace.Name,
+			MaxInheritedRole: TranslateRole(docAccess.MaxInheritedRole),
+			UserAccess:       tmpUsers,
 		}
 
-		switch output {
-		case "json":
-			{
-				jsonData, err := json.MarshalIndent(myDocAccess, "", "   ")
-				if err != nil {
-					fmt.Println(err)
-				}
-				fmt.Println(string(jsonData))
-			}
-		case "table":
-			{
-				// Displaying the document name
-				title := fmt.Sprintf("Workspace \"%s\" (n°%s), document \"%s\"", myDocAccess.WorkspaceName, myDocAccess.DocId, myDocAccess.DocName)
-				common.DisplayTitle(title)
-				fmt.Println(myDocAccess.MaxInheritedRole)
-				fmt.Printf("\nDirect users:\n")
-				table := tablewriter.NewWriter(os.Stdout)
-				table.SetHeader([]string{"Id", "Email", "Nom", "Inherited access", "Direct access"})
-				for _, user := range myDocAccess.UserAccess {
-					table.Append([]string{user.UserId, user.UserEmail, user.ParentAccess, user.Access})
-				}
-				table.Render()
+	}
+
+	switch output {
+	case "json":
+		{
+			jsonData, err := json.MarshalIndent(myDocAccess, "", "   ")
+			if err != nil {
+				fmt.Println(err)
 			}
+			fmt.Println(string(jsonData))
+		}
+	case "table":
+		{
+			// Displaying the document name
+			title := fmt.Sprintf("Workspace \"%s\" (n°%s), document \"%s\"", myDocAccess.WorkspaceName, myDocAccess.DocId, myDocAccess.DocName)
+			common.DisplayTitle(title)
+			fmt.Println(myDocAccess.MaxInheritedRole)
+			fmt.Printf("\nDirect users:\n")
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Id", "Email", "Nom", "Inherited access", "Direct access"})
+			for _, user := range myDocAccess.UserAccess {
+				table.Append([]string{user.UserId, user.UserEmail, user.ParentAccess, user.Access})
+			}
+			table.Render()
 		}
 	}
+	return nil
 }
 
 // Displaying the rights matrix
-func DisplayUserMatrix() {
+func DisplayUserMatrix() error {
 	type userAccess struct {
 		Id            int
 		Email         string
@@ -813,10 +885,21 @@ func DisplayUserMatrix() {
 	}
 	lstUserAccess := []userAccess{}
 
-	lstOrg := gristapi.GetOrgs()
+	lstOrg, err := client.GetOrgs(ctx)
+	if err != nil {
+		return err
+	}
 	for _, org := range lstOrg {
-		for _, ws := range gristapi.GetOrgWorkspaces(org.Id) {
-			for _, access := range gristapi.GetWorkspaceAccess(ws.Id).Users {
+		lstWorkspaces, err := client.GetOrgWorkspaces(ctx, org.Id)
+		if err != nil {
+			return err
+		}
+		for _, ws := range lstWorkspaces {
+			wsAccess, err := client.GetWorkspaceAccess(ctx, ws.Id)
+			if err != nil {
+				return err
+			}
+			for _, access := range wsAccess.Users {
 				tmpUserAccess := userAccess{
 					Id:            access.Id,
 					Email:         access.Email,
@@ -867,58 +950,125 @@ func DisplayUserMatrix() {
 			table.Render()
 		}
 	}
+	return nil
 }
 
-// Delete a workspace
-func DeleteWorkspace(workspaceId int) {
-	if common.Confirm(fmt.Sprintf("Do you really want to delete workspace %d ?", workspaceId)) {
-		gristapi.DeleteWorkspace(workspaceId)
+// Delete a workspace: move it to the trash, or delete it for good if permanent is true
+func DeleteWorkspace(workspaceId int, permanent bool) error {
+	if !permanent {
+		if common.Confirm(fmt.Sprintf("Do you really want to move workspace %d to the trash ?", workspaceId)) {
+			if err := client.RemoveWorkspace(ctx, workspaceId); err != nil {
+				return entityError("workspace", workspaceId, err)
+			}
+			fmt.Printf("Workspace %d moved to the trash (restore workspace %d to restore it)\t✅\n", workspaceId, workspaceId)
+		}
+		return nil
 	}
+	if common.Confirm(fmt.Sprintf("Do you really want to permanently delete workspace %d ?", workspaceId)) {
+		if err := client.DeleteWorkspace(ctx, workspaceId); err != nil {
+			return entityError("workspace", workspaceId, err)
+		}
+		fmt.Printf("Workspace %d deleted\t✅\n", workspaceId)
+	}
+	return nil
 }
 
-// Delete a document
-func DeleteDoc(docId string) {
-	if common.Confirm(fmt.Sprintf("Do you really want to delete document %s ?", docId)) {
-		gristapi.DeleteDoc(docId)
+// Delete a document: move it to the trash, or delete it for good if permanent is true
+func DeleteDoc(docId string, permanent bool) error {
+	if !permanent {
+		if common.Confirm(fmt.Sprintf("Do you really want to move document %s to the trash ?", docId)) {
+			if err := client.RemoveDoc(ctx, docId); err != nil {
+				return entityError("document", docId, err)
+			}
+			fmt.Printf("Document %s moved to the trash (restore doc %s to restore it)\t✅\n", docId, docId)
+		}
+		return nil
+	}
+	if common.Confirm(fmt.Sprintf("Do you really want to permanently delete document %s ?", docId)) {
+		if err := client.DeleteDoc(ctx, docId); err != nil {
+			return entityError("document", docId, err)
+		}
+		fmt.Printf("Document %s deleted\t✅\n", docId)
 	}
+	return nil
 }
 
 // Delete a user
-func DeleteUser(userId int) {
+func DeleteUser(userId int) error {
 	// Check if the user exists
-	user := gristapi.GetUser(userId)
-	if user.Name.Formatted == "" {
-		// User was not found
-		fmt.Printf("❗️ User %d not found ❗️\n", userId)
-		return
-	} else {
-		// User was found
-		DisplayUser(userId)
-		// Ask for confirmation
-		fmt.Println("⚠️  Warning :")
-		fmt.Printf("User %d will be deleted\n", user.Id)
+	user, err := client.GetUser(ctx, userId)
+	if err != nil {
+		return entityError("user", userId, err)
 	}
+	// User was found
+	if err := DisplayUser(userId); err != nil {
+		return err
+	}
+	// Ask for confirmation
+	fmt.Println("⚠️  Warning :")
+	fmt.Printf("User %d will be deleted\n", user.Id)
 	if common.Confirm(fmt.Sprintf("Do you really want to delete user %d ?", userId)) {
-		gristapi.DeleteUser(userId)
+		err := client.DeleteUser(ctx, userId)
+		switch gristapi.StatusCode(err) {
+		case http.StatusBadRequest:
+			return fmt.Errorf("the passed user name does not match the one retrieved from the database given the passed user id: %w", err)
+		case http.StatusForbidden:
+			return fmt.Errorf("the caller is not allowed to delete this account: %w", err)
+		case http.StatusNotFound:
+			return fmt.Errorf("the user is not found: %w", err)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println("The account has been deleted successfully")
 	}
+	return nil
 }
 
 // Export a document as a Grist file
-func ExportDocGrist(docId string) {
-	doc := gristapi.GetDoc(docId)
-	if doc.Name != "" {
-		gristapi.ExportDocGrist(docId, doc.Workspace.Name+"_"+doc.Name+".grist")
-	} else {
-		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
-	}
+// The file is named after the workspace and the document if fileName is empty,
+// or written to the standard output if fileName is "-"
+func ExportDocGrist(docId string, fileName string) error {
+	return exportDoc(docId, fileName, ".grist", client.ExportDocGrist)
 }
 
 // Export a document as an Excel file
-func ExportDocExcel(docId string) {
-	doc := gristapi.GetDoc(docId)
-	if doc.Name != "" {
-		gristapi.ExportDocExcel(docId, doc.Workspace.Name+"_"+doc.Name+".xlsx")
-	} else {
-		fmt.Printf("❗️ Document %s not found ❗️\n", docId)
+// The file is named after the workspace and the document if fileName is empty,
+// or written to the standard output if fileName is "-"
+func ExportDocExcel(docId string, fileName string) error {
+	return exportDoc(docId, fileName, ".xlsx", client.ExportDocExcel)
+}
+
+// Download a document export in fileName
+func exportDoc(docId string, fileName string, extension string, export func(context.Context, string, io.Writer) (int64, error)) error {
+	download := func(w io.Writer) (int64, error) {
+		return export(ctx, docId, w)
+	}
+	if fileName == "-" {
+		_, err := download(os.Stdout)
+		return entityError("document", docId, err)
+	}
+	if fileName == "" {
+		doc, err := client.GetDoc(ctx, docId)
+		if err != nil {
+			return entityError("document", docId, err)
+		}
+		fileName = doc.Workspace.Name + "_" + doc.Name + extension
+	}
+	size, err := gristapi.SaveToFile(fileName, download)
+	if err != nil {
+		return entityError("document", docId, err)
+	}
+	fmt.Printf("Document %s exported in %s (%d bytes) ✅\n", docId, fileName, size)
+	return nil
+}
+
+// Displays the content of a document's table as CSV
+func DisplayTableContent(docId string, tableName string) error {
+	content, err := client.GetTableContent(ctx, docId, tableName)
+	if err != nil {
+		return entityError("table", tableName, err)
 	}
+	fmt.Println(content)
+	return nil
 }
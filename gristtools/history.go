@@ -0,0 +1,129 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"encoding/json"
+	"fmt"
+	"gristctl/gristapi"
+	"os"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Formats a size in bytes with a readable unit
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit && size > -unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, units := float64(size)/unit, "KMGT"
+	i := 0
+	for (value >= unit || value <= -unit) && i < len(units)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, units[i])
+}
+
+// Displays the states of the action history of a document, the most recent first
+func DisplayDocStates(docId string) error {
+	states, err := client.GetDocStates(ctx, docId)
+	if err != nil {
+		return entityError("document", docId, err)
+	}
+
+	switch output {
+	case "table":
+		{
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"#", "Action", "Hash"})
+			for i, state := range states {
+				table.Append([]string{strconv.Itoa(i + 1), strconv.Itoa(state.N), state.H})
+			}
+			table.Render()
+			fmt.Printf("%d states in the history of document %s\n", len(states), docId)
+		}
+	case "json":
+		{
+			history := struct {
+				DocId  string              `json:"docId"`
+				Count  int                 `json:"count"`
+				States []gristapi.DocState `json:"states"`
+			}{docId, len(states), states}
+			jsonHistory, err := json.MarshalIndent(history, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(jsonHistory))
+		}
+	}
+	return nil
+}
+
+/*
+Displays the snapshots of a document, the most recent first
+
+With withSizes, the size of each snapshot is computed by downloading it,
+which can take a while for big documents.
+*/
+func DisplayDocSnapshots(docId string, withSizes bool) error {
+	snapshots, err := client.GetDocSnapshots(ctx, docId)
+	if err != nil {
+		return entityError("document", docId, err)
+	}
+
+	type snapshotSize struct {
+		gristapi.DocSnapshot
+		Size int64 `json:"size,omitempty"`
+	}
+	list := []snapshotSize{}
+	totalSize := int64(0)
+	for _, snapshot := range snapshots {
+		item := snapshotSize{DocSnapshot: snapshot}
+		if withSizes {
+			if item.Size, err = client.GetDocSize(ctx, snapshot.DocId); err != nil {
+				return entityError("snapshot", snapshot.SnapshotId, err)
+			}
+			totalSize += item.Size
+		}
+		list = append(list, item)
+	}
+
+	switch output {
+	case "table":
+		{
+			table := tablewriter.NewWriter(os.Stdout)
+			header := []string{"Snapshot", "Last modified", "Label"}
+			if withSizes {
+				header = append(header, "Size")
+			}
+			table.SetHeader(header)
+			for _, item := range list {
+				line := []string{item.SnapshotId, item.LastModified, item.Metadata.Label}
+				if withSizes {
+					line = append(line, formatSize(item.Size))
+				}
+				table.Append(line)
+			}
+			table.Render()
+			if withSizes {
+				fmt.Printf("%d snapshots of document %s (%s)\n", len(list), docId, formatSize(totalSize))
+			} else {
+				fmt.Printf("%d snapshots of document %s\n", len(list), docId)
+			}
+		}
+	case "json":
+		{
+			jsonSnapshots, err := json.MarshalIndent(list, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(jsonSnapshots))
+		}
+	}
+	return nil
+}
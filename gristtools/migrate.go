@@ -0,0 +1,275 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gristctl/gristapi"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// State of the migration of a workspace, saved after each step
+// to resume an interrupted migration
+type migrationState struct {
+	Source            string        `json:"source"` // URL of the source server
+	Target            string        `json:"target"` // URL of the target server
+	SourceWorkspaceId int           `json:"sourceWorkspaceId"`
+	WorkspaceName     string        `json:"workspaceName"`
+	TargetOrgId       int           `json:"targetOrgId,omitempty"`
+	TargetWorkspaceId int           `json:"targetWorkspaceId,omitempty"`
+	AccessMigrated    bool          `json:"accessMigrated"` // The access rights of the workspace were replayed
+	AccessError       string        `json:"accessError,omitempty"`
+	Docs              []migratedDoc `json:"docs"`
+}
+
+// Migration of a document
+type migratedDoc struct {
+	SourceDocId    string `json:"sourceDocId"`
+	Name           string `json:"name"`
+	TargetDocId    string `json:"targetDocId,omitempty"`
+	AccessMigrated bool   `json:"accessMigrated"` // The access rights of the document were replayed
+	Error          string `json:"error,omitempty"`
+}
+
+// Returns the message of an error, or "" if there is no error
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Reads the state of a migration (empty if the file does not exist)
+func loadMigrationState(fileName string) (migrationState, error) {
+	state := migrationState{}
+	content, err := os.ReadFile(fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(content, &state); err != nil {
+		return state, fmt.Errorf("invalid migration state %s: %w", fileName, err)
+	}
+	return state, nil
+}
+
+// Returns the migration of a document, added to the state if it is missing
+func (s *migrationState) doc(docId string, name string) *migratedDoc {
+	for i := range s.Docs {
+		if s.Docs[i].SourceDocId == docId {
+			return &s.Docs[i]
+		}
+	}
+	s.Docs = append(s.Docs, migratedDoc{SourceDocId: docId, Name: name})
+	return &s.Docs[len(s.Docs)-1]
+}
+
+// Returns the changes giving the access rights of a source entity to the target one
+// Users are mapped by email, and only their direct access is replayed
+func accessDelta(access gristapi.EntityAccess) gristapi.AccessDelta {
+	delta := gristapi.AccessDelta{MaxInheritedRole: &access.MaxInheritedRole, Users: map[string]*string{}}
+	for _, user := range access.Users {
+		if user.Access != "" && user.Email != "" {
+			role := user.Access
+			delta.Users[user.Email] = &role
+		}
+	}
+	return delta
+}
+
+// Returns the id of the organization of the target server with the given name
+func findOrg(target *gristapi.Client, name string) (int, error) {
+	orgs, err := target.GetOrgs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, org := range orgs {
+		if org.Name == name {
+			return org.Id, nil
+		}
+	}
+	return 0, fmt.Errorf("no organization '%s' on %s, use --org to choose the target organization", name, target.BaseURL())
+}
+
+// Copies a document of the source server into a workspace of the target server,
+// through a temporary .grist file
+// Returns the id of the new document
+func migrateDoc(source *gristapi.Client, target *gristapi.Client, doc gristapi.Doc, workspaceId int) (string, error) {
+	file, err := os.CreateTemp("", "gristctl-*.grist")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err := source.ExportDocGrist(ctx, doc.Id, file); err != nil {
+		return "", entityError("document", doc.Id, err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	docId, err := target.ImportDoc(ctx, workspaceId, doc.Name+".grist", file, doc.Name)
+	if err != nil {
+		return "", entityError("workspace", workspaceId, err)
+	}
+	return docId, nil
+}
+
+/*
+Migrates a workspace of the source server to the target server
+
+The workspace is created in the target organization (orgId, or else the
+organization with the same name as the source one), then its documents are
+copied as .grist files. The access rights of the workspace and of the
+documents are replayed, users being mapped by email.
+
+Each step is saved in stateFile: running the command again resumes the
+migration, retrying only what could not be migrated.
+*/
+func Migrate(source *gristapi.Client, target *gristapi.Client, workspaceId int, orgId int, stateFile string) error {
+	if stateFile == "" {
+		stateFile = fmt.Sprintf("gristctl-migrate-%d.json", workspaceId)
+	}
+	state, err := loadMigrationState(stateFile)
+	if err != nil {
+		return err
+	}
+	if state.Source == "" {
+		state = migrationState{Source: source.BaseURL(), Target: target.BaseURL(), SourceWorkspaceId: workspaceId, Docs: []migratedDoc{}}
+	} else if state.Source != source.BaseURL() || state.Target != target.BaseURL() || state.SourceWorkspaceId != workspaceId {
+		return fmt.Errorf("%s is the state of another migration (workspace %d from %s to %s)", stateFile, state.SourceWorkspaceId, state.Source, state.Target)
+	}
+	save := func() error {
+		if err := saveJSON(stateFile, state); err != nil {
+			return fmt.Errorf("error saving the state of the migration: %w", err)
+		}
+		return nil
+	}
+
+	workspace, err := source.GetWorkspace(ctx, workspaceId)
+	if err != nil {
+		return entityError("workspace", workspaceId, err)
+	}
+	state.WorkspaceName = workspace.Name
+
+	if state.TargetWorkspaceId == 0 {
+		if orgId == 0 {
+			orgId = state.TargetOrgId
+		}
+		if orgId == 0 {
+			if orgId, err = findOrg(target, workspace.Org.Name); err != nil {
+				return err
+			}
+		}
+		state.TargetOrgId = orgId
+		if state.TargetWorkspaceId, _, err = target.FindOrCreateWorkspace(ctx, orgId, workspace.Name); err != nil {
+			return entityError("organization", orgId, err)
+		}
+		if err := save(); err != nil {
+			return err
+		}
+	}
+
+	if !state.AccessMigrated {
+		access, err := source.GetWorkspaceAccess(ctx, workspaceId)
+		if err == nil {
+			err = target.UpdateWorkspaceAccess(ctx, state.TargetWorkspaceId, accessDelta(access))
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		state.AccessMigrated, state.AccessError = err == nil, errorText(err)
+		if err := save(); err != nil {
+			return err
+		}
+	}
+
+	for _, doc := range workspace.Docs {
+		migrated := state.doc(doc.Id, doc.Name)
+		if migrated.TargetDocId == "" {
+			migrated.TargetDocId, err = migrateDoc(source, target, doc, state.TargetWorkspaceId)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			migrated.Error = errorText(err)
+			if err := save(); err != nil {
+				return err
+			}
+		}
+		if migrated.TargetDocId != "" && !migrated.AccessMigrated {
+			access, err := source.GetDocAccess(ctx, doc.Id)
+			if err == nil {
+				err = target.UpdateDocAccess(ctx, migrated.TargetDocId, accessDelta(access))
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			migrated.AccessMigrated, migrated.Error = err == nil, ""
+			if err != nil {
+				migrated.Error = "access rights: " + err.Error()
+			}
+			if err := save(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := displayMigration(state); err != nil {
+		return err
+	}
+	nbErrors := 0
+	if !state.AccessMigrated {
+		nbErrors++
+	}
+	for _, doc := range state.Docs {
+		if doc.TargetDocId == "" || !doc.AccessMigrated {
+			nbErrors++
+		}
+	}
+	if nbErrors > 0 {
+		return fmt.Errorf("%d elements could not be migrated, run the command again to retry (state saved in %s)", nbErrors, stateFile)
+	}
+	return nil
+}
+
+// Displays the report of a migration
+func displayMigration(state migrationState) error {
+	switch output {
+	case "table":
+		{
+			status := func(done bool, err string) string {
+				if done {
+					return "✅"
+				}
+				return "❗️ " + err
+			}
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Type", "Name", "Source", "Target", "Status"})
+			table.Append([]string{"workspace", state.WorkspaceName, strconv.Itoa(state.SourceWorkspaceId), strconv.Itoa(state.TargetWorkspaceId), status(state.AccessMigrated, state.AccessError)})
+			for _, doc := range state.Docs {
+				table.Append([]string{"document", doc.Name, doc.SourceDocId, doc.TargetDocId, status(doc.TargetDocId != "" && doc.AccessMigrated, doc.Error)})
+			}
+			table.Render()
+		}
+	case "json":
+		{
+			jsonState, err := json.MarshalIndent(state, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(jsonState))
+		}
+	}
+	return nil
+}
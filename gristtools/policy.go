@@ -0,0 +1,338 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"encoding/json"
+	"fmt"
+	"gristctl/common"
+	"gristctl/gristapi"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Desired access rights of organizations, workspaces and documents
+type accessPolicy struct {
+	Orgs       []entityPolicy `json:"orgs"`
+	Workspaces []entityPolicy `json:"workspaces"`
+	Docs       []entityPolicy `json:"docs"`
+}
+
+// Desired access rights of an entity
+type entityPolicy struct {
+	Id          any               `json:"id"`          // Numeric id of an organization or a workspace, id of a document
+	Users       map[string]string `json:"users"`       // Role of each user, by email
+	Inheritance *string           `json:"inheritance"` // Maximum inherited role (unchanged if missing)
+}
+
+// Change of the access rights of an entity
+type accessChange struct {
+	Action  string `json:"action"` // add, remove, change or inheritance
+	User    string `json:"user,omitempty"`
+	Current string `json:"current,omitempty"`
+	Desired string `json:"desired,omitempty"`
+}
+
+// Changes needed to give an entity the access rights of the policy
+type entityAccessPlan struct {
+	Entity  string               `json:"entity"` // org, workspace or doc
+	Id      string               `json:"id"`
+	Changes []accessChange       `json:"changes"`
+	Applied bool                 `json:"applied,omitempty"`
+	Error   string               `json:"error,omitempty"`
+	delta   gristapi.AccessDelta // Changes sent to the access endpoint
+}
+
+// Reads an access policy in YAML or JSON format from a file (or from stdin)
+// A list of policies is merged into one policy
+func readAccessPolicy(fileName string) (accessPolicy, error) {
+	policy := accessPolicy{}
+	policies, err := readSpecs[accessPolicy](fileName)
+	if err != nil {
+		return policy, err
+	}
+	for _, p := range policies {
+		policy.Orgs = append(policy.Orgs, p.Orgs...)
+		policy.Workspaces = append(policy.Workspaces, p.Workspaces...)
+		policy.Docs = append(policy.Docs, p.Docs...)
+	}
+	return policy, nil
+}
+
+// Returns the id of the entity of a policy, numbers being read as float64
+func (p entityPolicy) id() string {
+	if number, isNumber := p.Id.(float64); isNumber {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	if p.Id == nil {
+		return ""
+	}
+	return fmt.Sprint(p.Id)
+}
+
+// Checks the roles of the policy of an entity
+func (p entityPolicy) check(entity string) error {
+	if p.id() == "" {
+		return fmt.Errorf("%s without id in the policy", entityNames[entity])
+	}
+	for email, role := range p.Users {
+		if err := checkRole(entity, role); err != nil {
+			return fmt.Errorf("%s %s, user %s: %w", entityNames[entity], p.id(), email, err)
+		}
+	}
+	if p.Inheritance != nil {
+		if entity == "org" {
+			return fmt.Errorf("organization %s: an organization has no inheritance", p.id())
+		}
+		if !slices.Contains(inheritanceRoles, *p.Inheritance) {
+			return fmt.Errorf("%s %s: invalid inheritance '%s', expected %s", entityNames[entity], p.id(), *p.Inheritance, strings.Join(inheritanceRoles, ", "))
+		}
+	}
+	return nil
+}
+
+// Reads the live access rights of the entity of a policy, and compares them with the policy
+// With prune, the users with a direct access which are not in the policy are removed
+func (p entityPolicy) plan(entity string, prune bool) entityAccessPlan {
+	access, err := getAccess(entity, p.id())
+	if err != nil {
+		return entityAccessPlan{Entity: entity, Id: p.id(), Changes: []accessChange{}, Error: err.Error()}
+	}
+	return p.compare(entity, access, prune)
+}
+
+// Compares the policy of an entity with its access rights
+func (p entityPolicy) compare(entity string, access gristapi.EntityAccess, prune bool) entityAccessPlan {
+	plan := entityAccessPlan{Entity: entity, Id: p.id(), Changes: []accessChange{}, delta: gristapi.AccessDelta{Users: map[string]*string{}}}
+
+	// Grist stores emails in lower case
+	current := map[string]string{}
+	for _, user := range access.Users {
+		if user.Email != "" && user.Access != "" {
+			current[strings.ToLower(user.Email)] = user.Access
+		}
+	}
+	desired := map[string]string{}
+	emails := []string{}
+	for email, role := range p.Users {
+		desired[strings.ToLower(email)] = role
+		emails = append(emails, email)
+	}
+	slices.Sort(emails)
+	for _, email := range emails {
+		role := p.Users[email]
+		currentRole, found := current[strings.ToLower(email)]
+		if currentRole == role {
+			continue
+		}
+		action := "add"
+		if found {
+			action = "change"
+		}
+		plan.Changes = append(plan.Changes, accessChange{Action: action, User: email, Current: currentRole, Desired: role})
+		plan.delta.Users[email] = &role
+	}
+	if prune {
+		unlisted := []string{}
+		for email, role := range current {
+			// Guests of an organization only have access to some of its documents or workspaces
+			if _, found := desired[email]; !found && role != "guests" {
+				unlisted = append(unlisted, email)
+			}
+		}
+		slices.Sort(unlisted)
+		for _, email := range unlisted {
+			plan.Changes = append(plan.Changes, accessChange{Action: "remove", User: email, Current: current[email]})
+			plan.delta.Users[email] = nil
+		}
+	}
+
+	if p.Inheritance != nil {
+		desiredRole := *p.Inheritance
+		if desiredRole == "none" {
+			desiredRole = ""
+		}
+		if desiredRole != access.MaxInheritedRole {
+			plan.Changes = append(plan.Changes, accessChange{Action: "inheritance", Current: inheritanceName(access.MaxInheritedRole), Desired: *p.Inheritance})
+			plan.delta.MaxInheritedRole = &desiredRole
+		}
+	}
+	return plan
+}
+
+// Returns the name of a maximum inherited role, "none" if nothing is inherited
+func inheritanceName(role string) string {
+	if role == "" {
+		return "none"
+	}
+	return role
+}
+
+// Compares an access policy with the live access rights of its entities
+func planAccessPolicy(fileName string, prune bool) ([]entityAccessPlan, error) {
+	policy, err := readAccessPolicy(fileName)
+	if err != nil {
+		return nil, err
+	}
+	entities := []struct {
+		entity   string
+		policies []entityPolicy
+	}{{"org", policy.Orgs}, {"workspace", policy.Workspaces}, {"doc", policy.Docs}}
+	for _, e := range entities {
+		for _, p := range e.policies {
+			if err := p.check(e.entity); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	plans := []entityAccessPlan{}
+	for _, e := range entities {
+		for _, p := range e.policies {
+			plans = append(plans, p.plan(e.entity, prune))
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+		}
+	}
+	return plans, nil
+}
+
+// Returns an error giving the number of entities whose access rights could not be read or updated
+func accessPlanErrors(plans []entityAccessPlan) error {
+	nbErrors := 0
+	for _, plan := range plans {
+		if plan.Error != "" {
+			nbErrors++
+		}
+	}
+	if nbErrors > 0 {
+		return fmt.Errorf("%d of %d entities could not be processed", nbErrors, len(plans))
+	}
+	return nil
+}
+
+/*
+Displays the changes needed to give organizations, workspaces and documents
+the access rights described in a YAML or JSON policy file
+
+The policy gives, for each entity, the role of its users and the maximum
+role inherited from its parent. With prune, the users with a direct access
+which are not listed in the policy are removed.
+*/
+func PlanAccess(fileName string, prune bool) error {
+	plans, err := planAccessPolicy(fileName, prune)
+	if err != nil {
+		return err
+	}
+	if err := displayAccessPlans(plans, false); err != nil {
+		return err
+	}
+	return accessPlanErrors(plans)
+}
+
+// Gives organizations, workspaces and documents the access rights described in a policy file,
+// after confirmation of the changes (unless yes is true)
+func ApplyAccess(fileName string, prune bool, yes bool) error {
+	plans, err := planAccessPolicy(fileName, prune)
+	if err != nil {
+		return err
+	}
+	nbEntities := 0
+	for _, plan := range plans {
+		if len(plan.Changes) > 0 {
+			nbEntities++
+		}
+	}
+	if nbEntities == 0 {
+		if err := displayAccessPlans(plans, false); err != nil {
+			return err
+		}
+		return accessPlanErrors(plans)
+	}
+	if !yes {
+		if err := displayAccessPlans(plans, false); err != nil {
+			return err
+		}
+		if !common.Confirm(fmt.Sprintf("Do you really want to change the access rights of %d entities ?", nbEntities)) {
+			return nil
+		}
+	}
+
+	for i := range plans {
+		if plans[i].Error != "" || len(plans[i].Changes) == 0 {
+			continue
+		}
+		err := updateAccess(plans[i].Entity, plans[i].Id, plans[i].delta)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		plans[i].Applied, plans[i].Error = err == nil, errorText(err)
+	}
+	if err := displayAccessPlans(plans, true); err != nil {
+		return err
+	}
+	return accessPlanErrors(plans)
+}
+
+// Displays the changes of access rights of entities, and their status once applied
+func displayAccessPlans(plans []entityAccessPlan, applied bool) error {
+	switch output {
+	case "table":
+		{
+			counts, nbRows := map[string]int{}, 0
+			table := tablewriter.NewWriter(os.Stdout)
+			header := []string{"Type", "Id", "Change", "User", "Current", "Desired"}
+			if applied {
+				header = append(header, "Status")
+			}
+			table.SetHeader(header)
+			for _, plan := range plans {
+				if plan.Error != "" && len(plan.Changes) == 0 {
+					// Without a status column, the error is given as the change
+					line := []string{plan.Entity, plan.Id, "❗️ " + plan.Error, "", "", ""}
+					if applied {
+						line = []string{plan.Entity, plan.Id, "", "", "", "", "❗️ " + plan.Error}
+					}
+					table.Append(line)
+					nbRows++
+					continue
+				}
+				status := "✅"
+				if plan.Error != "" {
+					status = "❗️ " + plan.Error
+				}
+				for _, change := range plan.Changes {
+					counts[change.Action]++
+					line := []string{plan.Entity, plan.Id, change.Action, change.User, change.Current, change.Desired}
+					if applied {
+						line = append(line, status)
+					}
+					table.Append(line)
+					nbRows++
+				}
+			}
+			if nbRows == 0 {
+				fmt.Println("Access rights already match the policy ✅")
+				return nil
+			}
+			table.Render()
+			fmt.Printf("%d additions, %d removals, %d role changes, %d inheritance changes\n", counts["add"], counts["remove"], counts["change"], counts["inheritance"])
+		}
+	case "json":
+		{
+			jsonPlans, err := json.MarshalIndent(plans, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(jsonPlans))
+		}
+	}
+	return nil
+}
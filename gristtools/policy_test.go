@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"fmt"
+	"gristctl/gristapi"
+	"slices"
+	"testing"
+)
+
+// Returns a summary of access changes, e.g. "change a@example.com viewers>editors"
+func summarizeAccessChanges(changes []accessChange) []string {
+	summary := []string{}
+	for _, change := range changes {
+		summary = append(summary, fmt.Sprintf("%s %s %s>%s", change.Action, change.User, change.Current, change.Desired))
+	}
+	return summary
+}
+
+// Returns a summary of an access delta, e.g. "a@example.com=editors", a removal being "a@example.com=nil"
+func summarizeDelta(delta gristapi.AccessDelta) []string {
+	summary := []string{}
+	for email, role := range delta.Users {
+		if role == nil {
+			summary = append(summary, email+"=nil")
+		} else {
+			summary = append(summary, email+"="+*role)
+		}
+	}
+	if delta.MaxInheritedRole != nil {
+		summary = append(summary, "inheritance="+*delta.MaxInheritedRole)
+	}
+	slices.Sort(summary)
+	return summary
+}
+
+func TestComparePolicy(t *testing.T) {
+	none, viewers := "none", "viewers"
+	access := gristapi.EntityAccess{MaxInheritedRole: "", Users: []gristapi.User{
+		{Email: "Alice@example.com", Access: "owners"},
+		{Email: "bob@example.com", Access: "viewers"},
+		{Email: "guest@example.com", Access: "guests"},
+		{Email: "inherited@example.com", Access: ""},
+	}}
+
+	tests := []struct {
+		name    string
+		policy  entityPolicy
+		prune   bool
+		changes []string
+		delta   []string
+	}{
+		{"same access", entityPolicy{Users: map[string]string{"alice@example.com": "owners", "bob@example.com": "viewers"}}, false, []string{}, []string{}},
+		{
+			"added and changed users",
+			entityPolicy{Users: map[string]string{"carol@example.com": "editors", "bob@example.com": "editors"}},
+			false,
+			[]string{"change bob@example.com viewers>editors", "add carol@example.com >editors"},
+			[]string{"bob@example.com=editors", "carol@example.com=editors"},
+		},
+		{
+			"pruned users, guests being kept",
+			entityPolicy{Users: map[string]string{"bob@example.com": "viewers"}},
+			true,
+			[]string{"remove alice@example.com owners>"},
+			[]string{"alice@example.com=nil"},
+		},
+		{
+			"emails compared case-insensitively",
+			entityPolicy{Users: map[string]string{"ALICE@example.com": "owners", "Bob@Example.com": "viewers"}},
+			true,
+			[]string{},
+			[]string{},
+		},
+		{"no inheritance already", entityPolicy{Inheritance: &none}, false, []string{}, []string{}},
+		{
+			"inheritance restored",
+			entityPolicy{Inheritance: &viewers},
+			false,
+			[]string{"inheritance  none>viewers"},
+			[]string{"inheritance=viewers"},
+		},
+	}
+	for _, test := range tests {
+		test.policy.Id = "doc"
+		plan := test.policy.compare("doc", access, test.prune)
+		if summary := summarizeAccessChanges(plan.Changes); !slices.Equal(summary, test.changes) {
+			t.Errorf("%s: unexpected changes %v, expected %v", test.name, summary, test.changes)
+		}
+		if summary := summarizeDelta(plan.delta); !slices.Equal(summary, test.delta) {
+			t.Errorf("%s: unexpected delta %v, expected %v", test.name, summary, test.delta)
+		}
+	}
+
+	// Removing the inheritance is sent as an empty role
+	access.MaxInheritedRole = "editors"
+	plan := entityPolicy{Id: "doc", Inheritance: &none}.compare("doc", access, false)
+	if summary := summarizeDelta(plan.delta); !slices.Equal(summary, []string{"inheritance="}) {
+		t.Errorf("Unexpected delta %v to remove the inheritance", summary)
+	}
+	if summary := summarizeAccessChanges(plan.Changes); !slices.Equal(summary, []string{"inheritance  editors>none"}) {
+		t.Errorf("Unexpected changes %v to remove the inheritance", summary)
+	}
+}
@@ -0,0 +1,259 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"encoding/json"
+	"fmt"
+	"gristctl/common"
+	"os"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Purge of the history of a document
+type docPurge struct {
+	DocId      string `json:"docId"`
+	Name       string `json:"name"`
+	States     int    `json:"states"`               // Number of states before the purge
+	Removed    int    `json:"removed"`              // Number of states removed by the purge
+	SizeBefore int64  `json:"sizeBefore,omitempty"` // Size of the document before the purge, in bytes
+	SizeAfter  int64  `json:"sizeAfter,omitempty"`  // Size of the document after the purge, in bytes
+	Error      string `json:"error,omitempty"`
+}
+
+// Computes the number of states removed by the purge of a document, keeping nbHisto states,
+// and with withSizes the size of the document (downloaded to be measured)
+func (p *docPurge) preview(nbHisto int, withSizes bool) error {
+	states, err := client.GetDocStates(ctx, p.DocId)
+	if err != nil {
+		return entityError("document", p.DocId, err)
+	}
+	p.States = len(states)
+	p.Removed = max(len(states)-nbHisto, 0)
+	if withSizes {
+		if p.SizeBefore, err = client.GetDocSize(ctx, p.DocId); err != nil {
+			return entityError("document", p.DocId, err)
+		}
+		p.SizeAfter = p.SizeBefore
+	}
+	return nil
+}
+
+// Purges the history of a document, keeping nbHisto states,
+// and with withSizes measures its new size
+func (p *docPurge) purge(nbHisto int, withSizes bool) error {
+	if p.Removed == 0 {
+		return nil
+	}
+	if err := client.PurgeDoc(ctx, p.DocId, nbHisto); err != nil {
+		return entityError("document", p.DocId, err)
+	}
+	if withSizes {
+		size, err := client.GetDocSize(ctx, p.DocId)
+		if err != nil {
+			return entityError("document", p.DocId, err)
+		}
+		p.SizeAfter = size
+	}
+	return nil
+}
+
+/*
+Purges the history of a document, to retain only the last nbHisto states
+
+The number of states to remove (and with withSizes the size of the document)
+are displayed first, and the purge must be confirmed, unless yes is true.
+*/
+func PurgeDoc(docId string, nbHisto int, yes bool, withSizes bool) error {
+	doc, err := client.GetDoc(ctx, docId)
+	if err != nil {
+		return entityError("document", docId, err)
+	}
+	purge := docPurge{DocId: docId, Name: doc.Name}
+	if err := purge.preview(nbHisto, withSizes); err != nil {
+		return err
+	}
+	if purge.Removed == 0 {
+		fmt.Printf("Document %s has %d states, nothing to purge ✅\n", docId, purge.States)
+		return nil
+	}
+	if withSizes {
+		fmt.Printf("Document %s (%s): %d of its %d states will be removed, its size is %s\n", doc.Name, docId, purge.Removed, purge.States, formatSize(purge.SizeBefore))
+	} else {
+		fmt.Printf("Document %s (%s): %d of its %d states will be removed\n", doc.Name, docId, purge.Removed, purge.States)
+	}
+	if !yes && !common.Confirm(fmt.Sprintf("Do you really want to purge the history of document %s ?", docId)) {
+		return nil
+	}
+
+	if err := purge.purge(nbHisto, withSizes); err != nil {
+		return err
+	}
+	switch output {
+	case "table":
+		if withSizes {
+			fmt.Printf("History purged: %d states removed, size %s → %s (%s reclaimed) ✅\n", purge.Removed, formatSize(purge.SizeBefore), formatSize(purge.SizeAfter), formatSize(purge.SizeBefore-purge.SizeAfter))
+		} else {
+			fmt.Printf("History purged: %d states removed ✅\n", purge.Removed)
+		}
+	case "json":
+		jsonPurge, err := json.MarshalIndent(purge, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(jsonPurge))
+	}
+	return nil
+}
+
+// Purges the history of all the documents of a workspace
+func PurgeWorkspace(workspaceId int, nbHisto int, yes bool, concurrency int, withSizes bool) error {
+	workspace, err := client.GetWorkspace(ctx, workspaceId)
+	if err != nil {
+		return entityError("workspace", workspaceId, err)
+	}
+	purges := []docPurge{}
+	for _, doc := range workspace.Docs {
+		purges = append(purges, docPurge{DocId: doc.Id, Name: doc.Name})
+	}
+	return purgeDocs(fmt.Sprintf("workspace %d", workspaceId), purges, nbHisto, yes, concurrency, withSizes)
+}
+
+// Purges the history of all the documents of an organization
+func PurgeOrg(orgId int, nbHisto int, yes bool, concurrency int, withSizes bool) error {
+	workspaces, err := client.GetOrgWorkspaces(ctx, orgId)
+	if err != nil {
+		return entityError("organization", orgId, err)
+	}
+	purges := []docPurge{}
+	for _, workspace := range workspaces {
+		for _, doc := range workspace.Docs {
+			purges = append(purges, docPurge{DocId: doc.Id, Name: doc.Name})
+		}
+	}
+	return purgeDocs(fmt.Sprintf("organization %d", orgId), purges, nbHisto, yes, concurrency, withSizes)
+}
+
+/*
+Purges the history of documents, concurrently, after confirmation (unless yes is true)
+
+A summary table gives the states removed for each document, and with withSizes
+the space reclaimed (each document is downloaded before and after its purge).
+*/
+func purgeDocs(scope string, purges []docPurge, nbHisto int, yes bool, concurrency int, withSizes bool) error {
+	if len(purges) == 0 {
+		fmt.Printf("No document in %s ✅\n", scope)
+		return nil
+	}
+
+	// Preview
+	forEachConcurrently(len(purges), concurrency, func(i int) {
+		if err := purges[i].preview(nbHisto, withSizes); err != nil {
+			purges[i].Error = err.Error()
+		}
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	nbStates, nbDocs, totalSize := 0, 0, int64(0)
+	for _, purge := range purges {
+		if purge.Error == "" && purge.Removed > 0 {
+			nbStates += purge.Removed
+			nbDocs++
+			totalSize += purge.SizeBefore
+		}
+	}
+	if nbDocs == 0 {
+		fmt.Printf("Nothing to purge in %s\n", scope)
+		if err := displayPurges(purges, withSizes); err != nil {
+			return err
+		}
+		return purgeErrors(purges)
+	}
+	if withSizes {
+		fmt.Printf("%d states of %d documents of %s will be removed, these documents use %s\n", nbStates, nbDocs, scope, formatSize(totalSize))
+	} else {
+		fmt.Printf("%d states of %d documents of %s will be removed\n", nbStates, nbDocs, scope)
+	}
+	if !yes && !common.Confirm(fmt.Sprintf("Do you really want to purge the history of the documents of %s ?", scope)) {
+		return nil
+	}
+
+	// Purge of the documents with states to remove
+	forEachConcurrently(len(purges), concurrency, func(i int) {
+		if purges[i].Error != "" {
+			return
+		}
+		if err := purges[i].purge(nbHisto, withSizes); err != nil {
+			purges[i].Error = err.Error()
+		}
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := displayPurges(purges, withSizes); err != nil {
+		return err
+	}
+	return purgeErrors(purges)
+}
+
+// Returns an error giving the number of documents which could not be purged, if any
+func purgeErrors(purges []docPurge) error {
+	nbErrors := 0
+	for _, purge := range purges {
+		if purge.Error != "" {
+			nbErrors++
+		}
+	}
+	if nbErrors > 0 {
+		return fmt.Errorf("%d of %d documents could not be purged", nbErrors, len(purges))
+	}
+	return nil
+}
+
+// Displays the purge of documents, with the total space reclaimed if withSizes is true
+func displayPurges(purges []docPurge, withSizes bool) error {
+	switch output {
+	case "table":
+		{
+			table := tablewriter.NewWriter(os.Stdout)
+			if withSizes {
+				table.SetHeader([]string{"Document", "States", "Removed", "Size before", "Size after", "Reclaimed", "Status"})
+			} else {
+				table.SetHeader([]string{"Document", "States", "Removed", "Status"})
+			}
+			reclaimed := int64(0)
+			for _, purge := range purges {
+				line := []string{fmt.Sprintf("%s (%s)", purge.Name, purge.DocId), strconv.Itoa(purge.States), strconv.Itoa(purge.Removed)}
+				sizes := []string{formatSize(purge.SizeBefore), formatSize(purge.SizeAfter), formatSize(purge.SizeBefore - purge.SizeAfter)}
+				status := "✅"
+				if purge.Error != "" {
+					line[2], sizes, status = "", []string{"", "", ""}, "❗️ "+purge.Error
+				} else {
+					reclaimed += purge.SizeBefore - purge.SizeAfter
+				}
+				if withSizes {
+					line = append(line, sizes...)
+				}
+				table.Append(append(line, status))
+			}
+			table.Render()
+			if withSizes {
+				fmt.Printf("%s reclaimed\n", formatSize(reclaimed))
+			}
+		}
+	case "json":
+		{
+			jsonPurges, err := json.MarshalIndent(purges, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(jsonPurges))
+		}
+	}
+	return nil
+}
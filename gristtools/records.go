@@ -0,0 +1,396 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"gristctl/common"
+	"gristctl/gristapi"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Record read from a JSON or CSV file
+type inputRecord struct {
+	Id      int
+	Require map[string]any
+	Fields  map[string]any
+}
+
+// Open the file to read, or the standard input if fileName is empty or "-"
+func openInput(fileName string) (io.ReadCloser, error) {
+	if fileName == "" || fileName == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(fileName)
+}
+
+/*
+Read the records of a JSON or CSV file (or of the standard input)
+
+JSON content is a list of objects, either with the columns as keys
+(`[{"id": 1, "Name": "Alice"}]`) or in the format of the Grist API
+(`{"records": [{"id": 1, "fields": {"Name": "Alice"}}]}`, with `require`
+for upserts).
+
+CSV content has a header line with the column ids, separated by ',' or ';'.
+The values are converted according to the type of the columns of the table.
+The "id" column contains the ids of the records.
+*/
+func readRecords(docId string, tableId string, fileName string) ([]inputRecord, error) {
+	file, err := openInput(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+
+	isJSON := strings.EqualFold(filepath.Ext(fileName), ".json")
+	if !isJSON && !strings.EqualFold(filepath.Ext(fileName), ".csv") {
+		trimmed := bytes.TrimSpace(content)
+		isJSON = len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{')
+	}
+	if isJSON {
+		return parseJSONRecords(content)
+	}
+
+	columns, err := client.GetTableColumns(ctx, docId, tableId)
+	if err != nil {
+		return nil, entityError("table", tableId, err)
+	}
+	types := map[string]string{}
+	for _, column := range columns.Columns {
+		types[column.Id] = column.Fields.Type
+	}
+	return parseCSVRecords(content, types)
+}
+
+// Parse records in JSON format
+func parseJSONRecords(content []byte) ([]inputRecord, error) {
+	var raw json.RawMessage
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON content: %w", err)
+	}
+	// Format of the Grist API: {"records": [...]}
+	wrapped := struct {
+		Records json.RawMessage `json:"records"`
+	}{}
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		if err := json.Unmarshal(raw, &wrapped); err != nil || wrapped.Records == nil {
+			return nil, fmt.Errorf("invalid JSON content: a list of records is expected")
+		}
+		raw = wrapped.Records
+	}
+
+	objects := []map[string]any{}
+	decoder = json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&objects); err != nil {
+		return nil, fmt.Errorf("invalid JSON content: a list of records is expected (%w)", err)
+	}
+
+	records := []inputRecord{}
+	for i, object := range objects {
+		record := inputRecord{Fields: map[string]any{}}
+		if id, found := object["id"]; found {
+			number, ok := id.(json.Number)
+			value, err := number.Int64()
+			if !ok || err != nil {
+				return nil, fmt.Errorf("record %d: invalid id %v", i+1, id)
+			}
+			record.Id = int(value)
+			delete(object, "id")
+		}
+		fields, hasFields := object["fields"].(map[string]any)
+		require, hasRequire := object["require"].(map[string]any)
+		if hasFields || hasRequire {
+			record.Fields = fields
+			record.Require = require
+		} else {
+			record.Fields = object
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Parse records in CSV format
+// types gives the Grist type of each column of the table
+func parseCSVRecords(content []byte, types map[string]string) ([]inputRecord, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	firstLine, _, _ := bufio.NewReader(bytes.NewReader(content)).ReadLine()
+	if strings.Count(string(firstLine), ";") > strings.Count(string(firstLine), ",") {
+		reader.Comma = ';'
+	}
+	lines, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV content: %w", err)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("invalid CSV content: the header line is missing")
+	}
+
+	header := lines[0]
+	records := []inputRecord{}
+	for i, line := range lines[1:] {
+		record := inputRecord{Fields: map[string]any{}}
+		for j, column := range header {
+			if column == "id" {
+				id, err := strconv.Atoi(line[j])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid id '%s'", i+2, line[j])
+				}
+				record.Id = id
+				continue
+			}
+			record.Fields[column] = convertValue(line[j], types[column])
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Convert a CSV value to the type of its Grist column
+// Values that cannot be converted are kept as text, as Grist does
+func convertValue(value string, columnType string) any {
+	kind, _, _ := strings.Cut(columnType, ":")
+	if value == "" {
+		switch kind {
+		case "", "Text", "Choice", "Any":
+			return ""
+		default:
+			return nil
+		}
+	}
+	switch kind {
+	case "Numeric", "ManualSortPos":
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			return number
+		}
+	case "Int", "Ref":
+		if number, err := strconv.Atoi(value); err == nil {
+			return number
+		}
+	case "Bool":
+		if boolean, err := strconv.ParseBool(value); err == nil {
+			return boolean
+		}
+	case "Date":
+		if date, err := time.Parse(time.DateOnly, value); err == nil {
+			return date.Unix()
+		}
+	case "DateTime":
+		for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+			if date, err := time.Parse(layout, value); err == nil {
+				return date.Unix()
+			}
+		}
+	case "ChoiceList", "RefList":
+		var list []any
+		if json.Unmarshal([]byte(value), &list) == nil && len(list) > 0 && list[0] == "L" {
+			return list
+		}
+	}
+	return value
+}
+
+// Format a value of a record for display
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number, bool:
+		return fmt.Sprint(v)
+	default:
+		content, _ := json.Marshal(v)
+		return string(content)
+	}
+}
+
+// Displays the records of a table
+// filter is a JSON object giving the allowed values of each column, e.g. {"Status": ["Open"]}
+func DisplayRecords(docId string, tableId string, filter string, sortBy string, limit int) error {
+	query := gristapi.RecordsQuery{Sort: sortBy, Limit: limit}
+	if filter != "" {
+		if err := json.Unmarshal([]byte(filter), &query.Filter); err != nil {
+			return fmt.Errorf("invalid filter %s, expected format: {\"column\": [value, ...]} (%w)", filter, err)
+		}
+	}
+	records, err := client.GetRecords(ctx, docId, tableId, query)
+	if err != nil {
+		return entityError("table", tableId, err)
+	}
+
+	switch output {
+	case "table":
+		{
+			// Columns are displayed in the order of the table
+			columns := []string{}
+			if tableColumns, err := client.GetTableColumns(ctx, docId, tableId); err == nil {
+				for _, column := range tableColumns.Columns {
+					columns = append(columns, column.Id)
+				}
+			} else if len(records) > 0 {
+				for column := range records[0].Fields {
+					columns = append(columns, column)
+				}
+				sort.Strings(columns)
+			}
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader(append([]string{"id"}, columns...))
+			table.SetAutoFormatHeaders(false)
+			for _, record := range records {
+				line := []string{strconv.Itoa(record.Id)}
+				for _, column := range columns {
+					line = append(line, formatValue(record.Fields[column]))
+				}
+				table.Append(line)
+			}
+			table.Render()
+		}
+	case "json":
+		{
+			jsonRecords, err := json.MarshalIndent(records, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(jsonRecords))
+		}
+	}
+	return nil
+}
+
+// Adds the records of a file (or of stdin) to a table
+func AddRecords(docId string, tableId string, fileName string) error {
+	records, err := readRecords(docId, tableId, fileName)
+	if err != nil {
+		return err
+	}
+	newRecords := []gristapi.Record{}
+	for _, record := range records {
+		newRecords = append(newRecords, gristapi.Record{Fields: record.Fields})
+	}
+	ids, err := client.AddRecords(ctx, docId, tableId, newRecords)
+	if err != nil {
+		return entityError("table", tableId, err)
+	}
+	fmt.Printf("%d records added ✅\n", len(ids))
+	return nil
+}
+
+// Updates records of a table with the content of a file (or of stdin)
+// Each record is identified by its id
+func UpdateRecords(docId string, tableId string, fileName string) error {
+	records, err := readRecords(docId, tableId, fileName)
+	if err != nil {
+		return err
+	}
+	updatedRecords := []gristapi.Record{}
+	for i, record := range records {
+		if record.Id <= 0 {
+			return fmt.Errorf("record %d has no id", i+1)
+		}
+		updatedRecords = append(updatedRecords, gristapi.Record{Id: record.Id, Fields: record.Fields})
+	}
+	if err := client.UpdateRecords(ctx, docId, tableId, updatedRecords); err != nil {
+		return entityError("table", tableId, err)
+	}
+	fmt.Printf("%d records updated ✅\n", len(updatedRecords))
+	return nil
+}
+
+// Adds or updates records of a table with the content of a file (or of stdin)
+// Records are matched on the values of the key columns,
+// unless they define their own "require" values (Grist API format)
+func UpsertRecords(docId string, tableId string, fileName string, keys []string) error {
+	records, err := readRecords(docId, tableId, fileName)
+	if err != nil {
+		return err
+	}
+	upsertRecords := []gristapi.UpsertRecord{}
+	for i, record := range records {
+		upsertRecord := gristapi.UpsertRecord{Require: record.Require, Fields: record.Fields}
+		if upsertRecord.Require == nil {
+			upsertRecord.Require = map[string]any{}
+			for _, key := range keys {
+				if value, found := record.Fields[key]; found {
+					upsertRecord.Require[key] = value
+					delete(upsertRecord.Fields, key)
+				}
+			}
+		}
+		if len(upsertRecord.Require) == 0 {
+			return fmt.Errorf("record %d has no value for the key columns (use --key <column>[,<column>...])", i+1)
+		}
+		upsertRecords = append(upsertRecords, upsertRecord)
+	}
+	if err := client.UpsertRecords(ctx, docId, tableId, upsertRecords); err != nil {
+		return entityError("table", tableId, err)
+	}
+	fmt.Printf("%d records added or updated ✅\n", len(upsertRecords))
+	return nil
+}
+
+// Deletes records of a table, after confirmation (unless yes is true)
+// The ids are given as arguments, or else read from a file (or from stdin)
+func DeleteRecords(docId string, tableId string, args []string, fileName string, yes bool) error {
+	ids := []int{}
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid record id '%s'", arg)
+		}
+		ids = append(ids, id)
+	}
+	if len(args) == 0 {
+		records, err := readRecords(docId, tableId, fileName)
+		if err != nil {
+			return err
+		}
+		for i, record := range records {
+			if record.Id <= 0 {
+				return fmt.Errorf("record %d has no id", i+1)
+			}
+			ids = append(ids, record.Id)
+		}
+	}
+	if len(ids) == 0 {
+		fmt.Println("No record to delete")
+		return nil
+	}
+	if !yes {
+		idList := []string{}
+		for _, id := range ids {
+			idList = append(idList, strconv.Itoa(id))
+		}
+		fmt.Printf("Records of table %s of document %s to delete: %s\n", tableId, docId, strings.Join(idList, ", "))
+		if !common.Confirm(fmt.Sprintf("Do you really want to delete %d records ?", len(ids))) {
+			return nil
+		}
+	}
+	if err := client.DeleteRecords(ctx, docId, tableId, ids); err != nil {
+		return entityError("table", tableId, err)
+	}
+	fmt.Printf("%d records deleted ✅\n", len(ids))
+	return nil
+}
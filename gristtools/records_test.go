@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestConvertValue(t *testing.T) {
+	tests := []struct {
+		value      string
+		columnType string
+		expected   any
+	}{
+		{"abc", "Text", "abc"},
+		{"", "Text", ""},
+		{"", "Numeric", nil},
+		{"", "", ""},
+		{"1.5", "Numeric", 1.5},
+		{"1,5", "Numeric", "1,5"}, // Kept as text, as Grist does
+		{"42", "Int", 42},
+		{"7", "Ref:People", 7},
+		{"true", "Bool", true},
+		{"2024-01-02", "Date", int64(1704153600)},
+		{"2024-01-02T10:00:00Z", "DateTime:Europe/Paris", int64(1704189600)},
+		{"2024-01-02 10:00:00", "DateTime:UTC", int64(1704189600)},
+		{`["L","a","b"]`, "ChoiceList", []any{"L", "a", "b"}},
+		{`a,b`, "ChoiceList", "a,b"},
+	}
+	for _, test := range tests {
+		value := convertValue(test.value, test.columnType)
+		if fmt.Sprintf("%#v", value) != fmt.Sprintf("%#v", test.expected) {
+			t.Errorf("convertValue(%q, %q) = %#v, expected %#v", test.value, test.columnType, value, test.expected)
+		}
+	}
+}
+
+func TestParseCSVRecords(t *testing.T) {
+	types := map[string]string{"Age": "Int", "Name": "Text"}
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+		err      bool
+	}{
+		{"comma", "id,Name,Age\n1,Alice,30\n2,Bob,\n", "[{1 map[Age:30 Name:Alice]} {2 map[Age:<nil> Name:Bob]}]", false},
+		{"semicolon", "Name;Age\nAlice;30\n", "[{0 map[Age:30 Name:Alice]}]", false},
+		{"invalid id", "id,Name\nx,Alice\n", "", true},
+		{"empty", "", "", true},
+		{"wrong number of fields", "Name,Age\nAlice\n", "", true},
+	}
+	for _, test := range tests {
+		records, err := parseCSVRecords([]byte(test.content), types)
+		if (err != nil) != test.err {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if test.err {
+			continue
+		}
+		summary := []string{}
+		for _, record := range records {
+			summary = append(summary, fmt.Sprint(struct {
+				Id     int
+				Fields map[string]any
+			}{record.Id, record.Fields}))
+		}
+		if fmt.Sprint(summary) != test.expected {
+			t.Errorf("%s: unexpected records %v, expected %s", test.name, summary, test.expected)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gristctl/common"
+	"gristctl/gristapi"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Actions of the restoration of a backup
+const (
+	restoreImport        = "import"
+	restoreSkipExisting  = "skip (existing document)"
+	restoreSkipNotBackup = "skip (not backed up)"
+)
+
+// Restoration of a document of a backup
+type restoreAction struct {
+	OrgId        int    `json:"orgId"`
+	Workspace    string `json:"workspace"`
+	WorkspaceId  int    `json:"workspaceId,omitempty"`
+	NewWorkspace bool   `json:"newWorkspace,omitempty"` // The workspace is created
+	Document     string `json:"document"`
+	File         string `json:"file,omitempty"`
+	Action       string `json:"action"`
+	DocId        string `json:"docId,omitempty"` // Id of the restored document
+	Error        string `json:"error,omitempty"`
+	sha256       string
+}
+
+/*
+Restores a .grist file as a new document of a workspace, named after the
+file unless a name is given, or replaces the content of an existing document
+if docId is not empty, after confirmation (unless yes is true)
+*/
+func RestoreDoc(fileName string, workspaceId int, docId string, name string, yes bool) error {
+	if !strings.EqualFold(filepath.Ext(fileName), ".grist") {
+		return fmt.Errorf("%s is not a .grist file", fileName)
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if docId == "" {
+		newDocId, err := client.ImportDoc(ctx, workspaceId, fileName, file, name)
+		if err != nil {
+			return entityError("workspace", workspaceId, err)
+		}
+		fmt.Printf("%s restored as document %s ✅\n", fileName, newDocId)
+		return nil
+	}
+
+	doc, err := client.GetDoc(ctx, docId)
+	if err != nil {
+		return entityError("document", docId, err)
+	}
+	if !yes && !common.Confirm(fmt.Sprintf("Do you really want to replace the content of document %s (%s) ?", doc.Name, docId)) {
+		return nil
+	}
+	// The file is imported as a temporary document of the same workspace,
+	// whose content then replaces the content of the document
+	tmpDocId, err := client.ImportDoc(ctx, doc.Workspace.Id, fileName, file, doc.Name+" (restore)")
+	if err != nil {
+		return entityError("workspace", doc.Workspace.Id, err)
+	}
+	defer func() {
+		if err := client.DeleteDoc(context.WithoutCancel(ctx), tmpDocId); err != nil {
+			fmt.Fprintf(os.Stderr, "❗️ Temporary document %s could not be deleted: %s\n", tmpDocId, err)
+		}
+	}()
+	if err := client.ReplaceDoc(ctx, docId, tmpDocId); err != nil {
+		return entityError("document", docId, err)
+	}
+	fmt.Printf("Document %s restored from %s ✅\n", docId, fileName)
+	return nil
+}
+
+// Returns the SHA-256 checksum of a file
+func fileChecksum(fileName string) (string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Computes the restoration of the documents of a backup in their organization,
+// or in the organization orgId if it is not 0
+// Workspaces are matched by name, and existing documents are not restored again
+func planRestore(manifest backupManifest, backupDir string, orgId int) ([]restoreAction, error) {
+	orgWorkspaces := map[int][]gristapi.Workspace{}
+	for _, doc := range manifest.Docs {
+		docOrgId := doc.OrgId
+		if orgId != 0 {
+			docOrgId = orgId
+		}
+		if _, found := orgWorkspaces[docOrgId]; found || doc.Error != "" || doc.File == "" {
+			continue
+		}
+		workspaces, err := client.GetOrgWorkspaces(ctx, docOrgId)
+		if err != nil {
+			return nil, entityError("organization", docOrgId, err)
+		}
+		orgWorkspaces[docOrgId] = workspaces
+	}
+	return restoreActions(manifest, backupDir, orgId, orgWorkspaces), nil
+}
+
+// Computes the restoration of the documents of a backup, given the existing
+// workspaces of the organizations in which they are restored
+func restoreActions(manifest backupManifest, backupDir string, orgId int, orgWorkspaces map[int][]gristapi.Workspace) []restoreAction {
+	// Existing workspaces and documents of each organization, by name
+	workspaces := map[int]map[string]int{}
+	docs := map[int]map[string]bool{}
+	for id, orgWorkspaces := range orgWorkspaces {
+		workspaces[id] = map[string]int{}
+		for _, workspace := range orgWorkspaces {
+			workspaces[id][workspace.Name] = workspace.Id
+			docs[workspace.Id] = map[string]bool{}
+			for _, doc := range workspace.Docs {
+				docs[workspace.Id][doc.Name] = true
+			}
+		}
+	}
+
+	actions := []restoreAction{}
+	for _, doc := range manifest.Docs {
+		action := restoreAction{OrgId: doc.OrgId, Workspace: doc.WorkspaceName, Document: doc.DocName, Action: restoreImport, sha256: doc.SHA256}
+		if orgId != 0 {
+			action.OrgId = orgId
+		}
+		if doc.Error != "" || doc.File == "" {
+			action.Action = restoreSkipNotBackup
+			actions = append(actions, action)
+			continue
+		}
+		action.File = filepath.Join(backupDir, doc.File)
+
+		if workspaces[action.OrgId] == nil {
+			workspaces[action.OrgId] = map[string]int{}
+		}
+		workspaceId, found := workspaces[action.OrgId][action.Workspace]
+		action.WorkspaceId = workspaceId
+		// The workspace is marked as new on its first document only
+		action.NewWorkspace = !found
+		workspaces[action.OrgId][action.Workspace] = workspaceId
+		if found && docs[workspaceId][action.Document] {
+			action.Action = restoreSkipExisting
+		}
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+// Restores a document of a backup, creating its workspace if needed
+// The ids of the created workspaces are added to workspaceIds (by organization and name)
+func restoreBackupDoc(action *restoreAction, workspaceIds map[string]int) error {
+	checksum, err := fileChecksum(action.File)
+	if err != nil {
+		return err
+	}
+	if action.sha256 != "" && checksum != action.sha256 {
+		return fmt.Errorf("the checksum of %s does not match the manifest", action.File)
+	}
+
+	// The missing workspace is created for the first of its documents which is restored
+	if action.WorkspaceId == 0 {
+		workspaceKey := strconv.Itoa(action.OrgId) + "/" + action.Workspace
+		workspaceId, found := workspaceIds[workspaceKey]
+		if !found {
+			workspaceId, err = client.CreateWorkspace(ctx, action.OrgId, action.Workspace)
+			if err != nil {
+				return entityError("organization", action.OrgId, err)
+			}
+			workspaceIds[workspaceKey] = workspaceId
+		}
+		action.WorkspaceId = workspaceId
+	}
+
+	file, err := os.Open(action.File)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	action.DocId, err = client.ImportDoc(ctx, action.WorkspaceId, action.File, file, action.Document)
+	if err != nil {
+		return entityError("workspace", action.WorkspaceId, err)
+	}
+	return nil
+}
+
+/*
+Restores all the documents of a backup, given by its manifest (or its directory)
+
+The documents are imported in the workspaces of the same name, created if
+they are missing, in their original organization or in the organization
+orgId if it is not 0. Documents which already exist are not restored again.
+With dryRun, the restoration is only displayed.
+*/
+func RestoreBackup(manifestPath string, orgId int, dryRun bool) error {
+	backupDir := manifestPath
+	if filepath.Base(manifestPath) == manifestFileName {
+		backupDir = filepath.Dir(manifestPath)
+	}
+	manifest, err := readManifest(backupDir)
+	if err != nil {
+		return err
+	}
+	actions, err := planRestore(manifest, backupDir, orgId)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		return displayRestore(actions)
+	}
+
+	workspaceIds := map[string]int{}
+	nbImports, nbErrors := 0, 0
+	for i := range actions {
+		action := &actions[i]
+		if action.Action != restoreImport {
+			continue
+		}
+		nbImports++
+		if err := restoreBackupDoc(action, workspaceIds); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			action.Error = err.Error()
+			nbErrors++
+		}
+	}
+	if err := displayRestore(actions); err != nil {
+		return err
+	}
+	if nbErrors > 0 {
+		return fmt.Errorf("%d of %d documents could not be restored", nbErrors, nbImports)
+	}
+	return nil
+}
+
+// Displays the restoration of the documents of a backup
+func displayRestore(actions []restoreAction) error {
+	switch output {
+	case "table":
+		{
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Organization", "Workspace", "Document", "Action", "Result"})
+			for _, action := range actions {
+				workspace := action.Workspace
+				if action.NewWorkspace {
+					workspace += " (new)"
+				}
+				result := action.DocId
+				if action.Error != "" {
+					result = "❗️ " + action.Error
+				}
+				table.Append([]string{strconv.Itoa(action.OrgId), workspace, action.Document, action.Action, result})
+			}
+			table.Render()
+		}
+	case "json":
+		{
+			jsonActions, err := json.MarshalIndent(actions, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(jsonActions))
+		}
+	}
+	return nil
+}
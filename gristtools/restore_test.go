@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"fmt"
+	"gristctl/gristapi"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRestoreActions(t *testing.T) {
+	manifest := backupManifest{Docs: []backupDoc{
+		{OrgId: 2, WorkspaceName: "Finance", DocName: "Budget", File: "ems/Finance/Budget.grist"},
+		{OrgId: 2, WorkspaceName: "Finance", DocName: "Invoices", File: "ems/Finance/Invoices.grist"},
+		{OrgId: 2, WorkspaceName: "IT", DocName: "Servers", File: "ems/IT/Servers.grist"},
+		{OrgId: 2, WorkspaceName: "IT", DocName: "Laptops", File: "ems/IT/Laptops.grist"},
+		{OrgId: 2, WorkspaceName: "IT", DocName: "Broken", Error: "timeout"},
+	}}
+	workspaces := []gristapi.Workspace{{Id: 10, Name: "Finance", Docs: []gristapi.Doc{{Id: "a", Name: "Budget"}}}}
+
+	tests := []struct {
+		name          string
+		orgId         int
+		orgWorkspaces map[int][]gristapi.Workspace
+		actions       []string // org/workspace id/new workspace/document/action
+	}{
+		{
+			"existing workspace and document",
+			0,
+			map[int][]gristapi.Workspace{2: workspaces},
+			[]string{
+				"2/10/false/Budget/" + restoreSkipExisting,
+				"2/10/false/Invoices/" + restoreImport,
+				"2/0/true/Servers/" + restoreImport,
+				"2/0/false/Laptops/" + restoreImport,
+				"2/0/false/Broken/" + restoreSkipNotBackup,
+			},
+		},
+		{
+			"other organization",
+			5,
+			map[int][]gristapi.Workspace{5: {}},
+			[]string{
+				"5/0/true/Budget/" + restoreImport,
+				"5/0/false/Invoices/" + restoreImport,
+				"5/0/true/Servers/" + restoreImport,
+				"5/0/false/Laptops/" + restoreImport,
+				"5/0/false/Broken/" + restoreSkipNotBackup,
+			},
+		},
+	}
+	for _, test := range tests {
+		actions := restoreActions(manifest, "/backups/20240311-100000", test.orgId, test.orgWorkspaces)
+		summary := []string{}
+		for _, action := range actions {
+			summary = append(summary, fmt.Sprintf("%d/%d/%v/%s/%s", action.OrgId, action.WorkspaceId, action.NewWorkspace, action.Document, action.Action))
+		}
+		if !slices.Equal(summary, test.actions) {
+			t.Errorf("%s: unexpected actions %v, expected %v", test.name, summary, test.actions)
+		}
+		if file := filepath.ToSlash(actions[0].File); file != "/backups/20240311-100000/ems/Finance/Budget.grist" {
+			t.Errorf("%s: unexpected file %s", test.name, file)
+		}
+	}
+}
@@ -0,0 +1,432 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gristctl/common"
+	"gristctl/gristapi"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+)
+
+// Kinds of schema changes
+const (
+	addTable     = "add table"
+	removeTable  = "remove table"
+	addColumn    = "add column"
+	modifyColumn = "modify column"
+	removeColumn = "remove column"
+)
+
+// Change turning a document schema into another
+type schemaChange struct {
+	Action  string                `json:"action"`
+	Table   string                `json:"table"`
+	Column  string                `json:"column,omitempty"`
+	Changes []fieldChange         `json:"changes,omitempty"`
+	table   *gristapi.TableSchema // Table to add
+	column  *gristapi.Column      // Column to add, or properties to modify
+}
+
+// Change of a property of a column
+type fieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Returns true if two widget options (JSON objects) are the same
+func sameWidgetOptions(a string, b string) bool {
+	if a == b {
+		return true
+	}
+	var valueA, valueB any
+	if json.Unmarshal([]byte(a), &valueA) != nil || json.Unmarshal([]byte(b), &valueB) != nil {
+		return false
+	}
+	return reflect.DeepEqual(valueA, valueB)
+}
+
+// Properties of a column which can be set to an empty value
+var clearableFields = []string{"label", "formula", "widgetOptions", "description"}
+
+// Compares the properties of a column with the expected ones
+// Unless all is true, properties which are not defined in expected are ignored,
+// empty properties being defined only if they are listed in expected.Cleared
+// Returns the properties to modify, and the description of the changes
+func diffColumnFields(current gristapi.ColumnFields, expected gristapi.ColumnFields, all bool) (gristapi.ColumnFields, []fieldChange) {
+	fields := gristapi.ColumnFields{}
+	changes := []fieldChange{}
+	compare := func(name string, from string, to string, same bool) bool {
+		cleared := slices.Contains(expected.Cleared, name) && slices.Contains(clearableFields, name)
+		if (to == "" && !all && !cleared) || same {
+			return false
+		}
+		changes = append(changes, fieldChange{Field: name, From: from, To: to})
+		if to == "" && slices.Contains(clearableFields, name) {
+			fields.Cleared = append(fields.Cleared, name)
+		}
+		return true
+	}
+	if compare("type", current.Type, expected.Type, current.Type == expected.Type) {
+		fields.Type = expected.Type
+	}
+	if compare("label", current.Label, expected.Label, current.Label == expected.Label) {
+		fields.Label = expected.Label
+	}
+	if compare("formula", current.Formula, expected.Formula, current.Formula == expected.Formula) {
+		fields.Formula = expected.Formula
+	}
+	if expected.IsFormula != nil || all {
+		from := current.IsFormula != nil && *current.IsFormula
+		to := expected.IsFormula != nil && *expected.IsFormula
+		if compare("isFormula", strconv.FormatBool(from), strconv.FormatBool(to), from == to) {
+			fields.IsFormula = &to
+		}
+	}
+	if compare("widgetOptions", current.WidgetOptions, expected.WidgetOptions, sameWidgetOptions(current.WidgetOptions, expected.WidgetOptions)) {
+		fields.WidgetOptions = expected.WidgetOptions
+	}
+	if compare("description", current.Description, expected.Description, current.Description == expected.Description) {
+		fields.Description = expected.Description
+	}
+	return fields, changes
+}
+
+// Computes the changes turning the current schema into the expected one
+// Tables and columns are listed in the order of the expected schema, then of the current one
+// Unless all is true, the properties of columns which are not defined in expected are ignored
+func diffSchema(current gristapi.DocSchema, expected gristapi.DocSchema, all bool) []schemaChange {
+	changes := []schemaChange{}
+	currentTables := map[string]gristapi.TableSchema{}
+	for _, table := range current.Tables {
+		currentTables[table.Id] = table
+	}
+	expectedTables := map[string]bool{}
+
+	for _, expectedTable := range expected.Tables {
+		expectedTables[expectedTable.Id] = true
+		currentTable, found := currentTables[expectedTable.Id]
+		if !found {
+			changes = append(changes, schemaChange{Action: addTable, Table: expectedTable.Id, table: &expectedTable})
+			continue
+		}
+
+		currentColumns := map[string]gristapi.Column{}
+		for _, column := range currentTable.Columns {
+			currentColumns[column.Id] = column
+		}
+		expectedColumns := map[string]bool{}
+		for _, expectedColumn := range expectedTable.Columns {
+			expectedColumns[expectedColumn.Id] = true
+			currentColumn, found := currentColumns[expectedColumn.Id]
+			if !found {
+				changes = append(changes, schemaChange{Action: addColumn, Table: expectedTable.Id, Column: expectedColumn.Id, column: &expectedColumn})
+				continue
+			}
+			fields, fieldChanges := diffColumnFields(currentColumn.Fields, expectedColumn.Fields, all)
+			if len(fieldChanges) > 0 {
+				changes = append(changes, schemaChange{
+					Action:  modifyColumn,
+					Table:   expectedTable.Id,
+					Column:  expectedColumn.Id,
+					Changes: fieldChanges,
+					column:  &gristapi.Column{Id: expectedColumn.Id, Fields: fields},
+				})
+			}
+		}
+		for _, column := range currentTable.Columns {
+			if !expectedColumns[column.Id] {
+				changes = append(changes, schemaChange{Action: removeColumn, Table: currentTable.Id, Column: column.Id})
+			}
+		}
+	}
+
+	for _, table := range current.Tables {
+		if !expectedTables[table.Id] {
+			changes = append(changes, schemaChange{Action: removeTable, Table: table.Id})
+		}
+	}
+	return changes
+}
+
+// Displays a list of schema changes
+func displaySchemaChanges(changes []schemaChange) error {
+	switch output {
+	case "table":
+		{
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Action", "Table", "Column", "Changes"})
+			table.SetAutoWrapText(false)
+			for _, change := range changes {
+				details := ""
+				for i, fieldChange := range change.Changes {
+					if i > 0 {
+						details += "\n"
+					}
+					details += fmt.Sprintf("%s: %q → %q", fieldChange.Field, fieldChange.From, fieldChange.To)
+				}
+				table.Append([]string{change.Action, change.Table, change.Column, details})
+			}
+			table.Render()
+		}
+	case "json":
+		{
+			jsonChanges, err := json.MarshalIndent(changes, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(jsonChanges))
+		}
+	}
+	return nil
+}
+
+// Encodes a document schema in YAML, or in JSON if asJSON is true
+func marshalSchema(schema gristapi.DocSchema, asJSON bool) ([]byte, error) {
+	var content bytes.Buffer
+	var err error
+	if asJSON {
+		encoder := json.NewEncoder(&content)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(schema)
+	} else {
+		encoder := yaml.NewEncoder(&content)
+		encoder.SetIndent(2)
+		err = encoder.Encode(schema)
+	}
+	return content.Bytes(), err
+}
+
+// Writes the schema of a document in YAML (or JSON with -o json),
+// in fileName or in stdout if it is empty or "-"
+func ExportSchema(docId string, fileName string) error {
+	schema, err := client.GetDocSchema(ctx, docId)
+	if err != nil {
+		return entityError("document", docId, err)
+	}
+	content, err := marshalSchema(schema, output == "json")
+	if err != nil {
+		return err
+	}
+
+	if fileName == "" || fileName == "-" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+	if err := os.WriteFile(fileName, content, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Schema of document %s exported in %s ✅\n", docId, fileName)
+	return nil
+}
+
+// Reads a document schema in YAML or JSON format from a file (or from stdin)
+func readSchema(fileName string) (gristapi.DocSchema, error) {
+	schema := gristapi.DocSchema{}
+	file, err := openInput(fileName)
+	if err != nil {
+		return schema, err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return schema, fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+	// Read as YAML then decoded as JSON, like the other specifications (see readSpecs)
+	var spec any
+	if err := yaml.Unmarshal(content, &spec); err != nil {
+		return schema, fmt.Errorf("invalid schema: %w", err)
+	}
+	jsonSpec, err := json.Marshal(spec)
+	if err != nil {
+		return schema, fmt.Errorf("invalid schema: %w", err)
+	}
+	if err := json.Unmarshal(jsonSpec, &schema); err != nil {
+		return schema, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	// The properties defined with an empty value are cleared
+	var defined struct {
+		Tables []struct {
+			Columns []struct {
+				Fields map[string]any `yaml:"fields"`
+			} `yaml:"columns"`
+		} `yaml:"tables"`
+	}
+	if err := yaml.Unmarshal(content, &defined); err != nil {
+		return schema, fmt.Errorf("invalid schema: %w", err)
+	}
+	for i, table := range defined.Tables {
+		for j, column := range table.Columns {
+			for _, name := range clearableFields {
+				if value, found := column.Fields[name]; found && (value == nil || value == "") {
+					schema.Tables[i].Columns[j].Fields.Cleared = append(schema.Tables[i].Columns[j].Fields.Cleared, name)
+				}
+			}
+		}
+	}
+	for i, table := range schema.Tables {
+		if table.Id == "" {
+			return schema, fmt.Errorf("invalid schema: table %d has no id", i+1)
+		}
+		for j, column := range table.Columns {
+			if column.Id == "" {
+				return schema, fmt.Errorf("invalid schema: column %d of table %s has no id", j+1, table.Id)
+			}
+		}
+	}
+	return schema, nil
+}
+
+/*
+Applies a schema to a document: missing tables and columns are created,
+and the properties of the columns are modified.
+With prune, the tables and columns which are not in the schema are removed,
+with their data, after confirmation (unless yes is true).
+With dryRun, the changes are only displayed.
+*/
+func ApplySchema(docId string, fileName string, dryRun bool, prune bool, yes bool) error {
+	expected, err := readSchema(fileName)
+	if err != nil {
+		return err
+	}
+	current, err := client.GetDocSchema(ctx, docId)
+	if err != nil {
+		return entityError("document", docId, err)
+	}
+	changes := diffSchema(current, expected, false)
+	nbRemovals := 0
+	for _, change := range changes {
+		if change.Action == removeTable || change.Action == removeColumn {
+			nbRemovals++
+		}
+	}
+	if !prune {
+		changes = slices.DeleteFunc(changes, func(change schemaChange) bool {
+			return change.Action == removeTable || change.Action == removeColumn
+		})
+	}
+	if len(changes) == 0 {
+		fmt.Printf("Document %s is up to date ✅\n", docId)
+	} else if err := displaySchemaChanges(changes); err != nil {
+		return err
+	}
+	if !prune && nbRemovals > 0 {
+		fmt.Printf("%d tables and columns which are not in the schema are kept, use --prune to remove them\n", nbRemovals)
+	}
+	if dryRun || len(changes) == 0 {
+		return nil
+	}
+	if prune && nbRemovals > 0 && !yes && !common.Confirm(fmt.Sprintf("Do you really want to remove %d tables and columns of document %s, with their data ?", nbRemovals, docId)) {
+		return nil
+	}
+
+	// Tables and columns are created before being used by the modified
+	// columns (references, formulas), and removed last
+	newTables := []gristapi.TableSchema{}
+	newColumns := map[string][]gristapi.Column{}
+	modifiedColumns := map[string][]gristapi.Column{}
+	tableIds := []string{}
+	for _, change := range changes {
+		switch change.Action {
+		case addTable:
+			newTables = append(newTables, *change.table)
+		case addColumn, modifyColumn:
+			if newColumns[change.Table] == nil && modifiedColumns[change.Table] == nil {
+				tableIds = append(tableIds, change.Table)
+			}
+			if change.Action == addColumn {
+				newColumns[change.Table] = append(newColumns[change.Table], *change.column)
+			} else {
+				modifiedColumns[change.Table] = append(modifiedColumns[change.Table], *change.column)
+			}
+		}
+	}
+	if len(newTables) > 0 {
+		if _, err := client.CreateTables(ctx, docId, newTables); err != nil {
+			return entityError("document", docId, err)
+		}
+	}
+	for _, tableId := range tableIds {
+		if len(newColumns[tableId]) > 0 {
+			if _, err := client.AddColumns(ctx, docId, tableId, newColumns[tableId]); err != nil {
+				return entityError("table", tableId, err)
+			}
+		}
+		if len(modifiedColumns[tableId]) > 0 {
+			if err := client.UpdateColumns(ctx, docId, tableId, modifiedColumns[tableId]); err != nil {
+				return entityError("table", tableId, err)
+			}
+		}
+	}
+	for _, change := range changes {
+		switch change.Action {
+		case removeColumn:
+			err = client.DeleteColumn(ctx, docId, change.Table, change.Column)
+		case removeTable:
+			err = client.DeleteTable(ctx, docId, change.Table)
+		}
+		if err != nil {
+			return entityError("table", change.Table, err)
+		}
+	}
+	fmt.Printf("%d changes applied to document %s ✅\n", len(changes), docId)
+	return nil
+}
+
+/*
+Displays the differences between the schemas of two documents,
+as the changes turning the first one into the second one,
+or as a unified diff of their YAML description (-o unified)
+Returns ErrDifferent if the schemas are different
+*/
+func DiffSchema(docIdA string, docIdB string) error {
+	schemaA, err := client.GetDocSchema(ctx, docIdA)
+	if err != nil {
+		return entityError("document", docIdA, err)
+	}
+	schemaB, err := client.GetDocSchema(ctx, docIdB)
+	if err != nil {
+		return entityError("document", docIdB, err)
+	}
+
+	changes := diffSchema(schemaA, schemaB, true)
+	if output == "unified" {
+		contentA, err := marshalSchema(schemaA, false)
+		if err != nil {
+			return err
+		}
+		contentB, err := marshalSchema(schemaB, false)
+		if err != nil {
+			return err
+		}
+		linesA := strings.Split(strings.TrimSuffix(string(contentA), "\n"), "\n")
+		linesB := strings.Split(strings.TrimSuffix(string(contentB), "\n"), "\n")
+		fmt.Print(unifiedDiff(docIdA, docIdB, linesA, linesB))
+	} else if len(changes) > 0 || output == "json" {
+		if err := displaySchemaChanges(changes); err != nil {
+			return err
+		}
+	}
+
+	if len(changes) > 0 {
+		return ErrDifferent
+	}
+	if output == "table" {
+		fmt.Println("The schemas are the same ✅")
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"fmt"
+	"gristctl/gristapi"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// Returns a summary of schema changes, e.g. "modify column People.Age [label]"
+func summarizeChanges(changes []schemaChange) []string {
+	summary := []string{}
+	for _, change := range changes {
+		line := change.Action + " " + change.Table
+		if change.Column != "" {
+			line += "." + change.Column
+		}
+		if len(change.Changes) > 0 {
+			fields := []string{}
+			for _, fieldChange := range change.Changes {
+				fields = append(fields, fieldChange.Field)
+			}
+			line += fmt.Sprint(fields)
+		}
+		summary = append(summary, line)
+	}
+	return summary
+}
+
+func TestDiffSchema(t *testing.T) {
+	isFormula := true
+	column := func(id string, fields gristapi.ColumnFields) gristapi.Column {
+		return gristapi.Column{Id: id, Fields: fields}
+	}
+	current := gristapi.DocSchema{Tables: []gristapi.TableSchema{
+		{Id: "People", Columns: []gristapi.Column{
+			column("Name", gristapi.ColumnFields{Type: "Text", Label: "Name"}),
+			column("Age", gristapi.ColumnFields{Type: "Int", Label: "Age", Formula: "$Birth", IsFormula: &isFormula}),
+			column("Old", gristapi.ColumnFields{Type: "Text"}),
+		}},
+		{Id: "Archive"},
+	}}
+
+	tests := []struct {
+		name     string
+		expected gristapi.DocSchema
+		all      bool
+		changes  []string
+	}{
+		{"same schema", current, false, []string{}},
+		{
+			"new table and column",
+			gristapi.DocSchema{Tables: []gristapi.TableSchema{
+				{Id: "People", Columns: []gristapi.Column{current.Tables[0].Columns[0], current.Tables[0].Columns[1], current.Tables[0].Columns[2], column("City", gristapi.ColumnFields{Type: "Text"})}},
+				{Id: "Archive"},
+				{Id: "Cities"},
+			}},
+			false,
+			[]string{"add column People.City", "add table Cities"},
+		},
+		{
+			"removed table and column",
+			gristapi.DocSchema{Tables: []gristapi.TableSchema{{Id: "People", Columns: current.Tables[0].Columns[:2]}}},
+			false,
+			[]string{"remove column People.Old", "remove table Archive"},
+		},
+		{
+			"undefined properties are ignored",
+			gristapi.DocSchema{Tables: []gristapi.TableSchema{
+				{Id: "People", Columns: []gristapi.Column{column("Name", gristapi.ColumnFields{Label: "Full name"}), column("Age", gristapi.ColumnFields{}), column("Old", gristapi.ColumnFields{})}},
+				{Id: "Archive"},
+			}},
+			false,
+			[]string{"modify column People.Name[label]"},
+		},
+		{
+			"cleared properties",
+			gristapi.DocSchema{Tables: []gristapi.TableSchema{
+				{Id: "People", Columns: []gristapi.Column{column("Name", gristapi.ColumnFields{}), column("Age", gristapi.ColumnFields{Cleared: []string{"formula", "description"}}), column("Old", gristapi.ColumnFields{})}},
+				{Id: "Archive"},
+			}},
+			false,
+			[]string{"modify column People.Age[formula]"},
+		},
+		{
+			"all properties are compared",
+			gristapi.DocSchema{Tables: []gristapi.TableSchema{
+				{Id: "People", Columns: []gristapi.Column{current.Tables[0].Columns[0], column("Age", gristapi.ColumnFields{Type: "Int", Label: "Age"}), current.Tables[0].Columns[2]}},
+				{Id: "Archive"},
+			}},
+			true,
+			[]string{"modify column People.Age[formula isFormula]"},
+		},
+	}
+	for _, test := range tests {
+		changes := diffSchema(current, test.expected, test.all)
+		if summary := summarizeChanges(changes); !slices.Equal(summary, test.changes) {
+			t.Errorf("%s: unexpected changes %v, expected %v", test.name, summary, test.changes)
+		}
+	}
+}
+
+func TestDiffColumnFields(t *testing.T) {
+	current := gristapi.ColumnFields{Type: "Text", Label: "Name", Formula: "$A", WidgetOptions: `{"a": 1, "b": 2}`}
+	tests := []struct {
+		name     string
+		expected gristapi.ColumnFields
+		fields   gristapi.ColumnFields
+	}{
+		{"nothing defined", gristapi.ColumnFields{}, gristapi.ColumnFields{}},
+		{"same widget options", gristapi.ColumnFields{WidgetOptions: `{"b":2,"a":1}`}, gristapi.ColumnFields{}},
+		{"new label", gristapi.ColumnFields{Label: "Full name"}, gristapi.ColumnFields{Label: "Full name"}},
+		{"cleared formula", gristapi.ColumnFields{Cleared: []string{"formula"}}, gristapi.ColumnFields{Cleared: []string{"formula"}}},
+		{"already empty description", gristapi.ColumnFields{Cleared: []string{"description"}}, gristapi.ColumnFields{}},
+	}
+	for _, test := range tests {
+		fields, _ := diffColumnFields(current, test.expected, false)
+		if fmt.Sprintf("%+v", fields) != fmt.Sprintf("%+v", test.fields) {
+			t.Errorf("%s: unexpected properties to modify %+v, expected %+v", test.name, fields, test.fields)
+		}
+	}
+}
+
+func TestReadSchema(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "schema.yaml")
+	content := `tables:
+  - id: People
+    columns:
+      - id: Name
+        fields:
+          label: Name
+          formula: ""
+          description:
+      - id: Status
+        fields:
+          type: Choice
+          widgetOptions:
+            choices: [Open, Closed]
+`
+	if err := os.WriteFile(fileName, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := readSchema(fileName)
+	if err != nil {
+		t.Fatalf("Error reading the schema : %s", err)
+	}
+	if cleared := schema.Tables[0].Columns[0].Fields.Cleared; !slices.Equal(cleared, []string{"formula", "description"}) {
+		t.Errorf("Unexpected cleared properties %v", cleared)
+	}
+	if options := schema.Tables[0].Columns[1].Fields.WidgetOptions; options != `{"choices":["Open","Closed"]}` {
+		t.Errorf("Unexpected widget options %s", options)
+	}
+}
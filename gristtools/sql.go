@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Converts a parameter of a SQL query given on the command line
+// JSON numbers, booleans and null keep their type, other values are strings
+func sqlArg(arg string) any {
+	var value any
+	decoder := json.NewDecoder(strings.NewReader(arg))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err == nil && !decoder.More() {
+		switch value.(type) {
+		case json.Number, bool, nil:
+			return value
+		}
+	}
+	return arg
+}
+
+// Runs a SQL query on a document and displays its result
+// The query is read from fileName (or stdin if fileName is "-") when it is empty
+func QuerySQL(docId string, query string, args []string, fileName string) error {
+	if query == "" {
+		file, err := openInput(fileName)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		content, err := io.ReadAll(file)
+		if err != nil {
+			return fmt.Errorf("error reading the query: %w", err)
+		}
+		query = strings.TrimSpace(string(content))
+	}
+	sqlArgs := []any{}
+	for _, arg := range args {
+		sqlArgs = append(sqlArgs, sqlArg(arg))
+	}
+
+	result, err := client.QuerySQL(ctx, docId, query, sqlArgs)
+	if err != nil {
+		return entityError("document", docId, err)
+	}
+
+	switch output {
+	case "table":
+		{
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader(result.Columns)
+			table.SetAutoFormatHeaders(false)
+			for _, row := range result.Rows {
+				line := []string{}
+				for _, value := range row {
+					line = append(line, formatValue(value))
+				}
+				table.Append(line)
+			}
+			table.Render()
+		}
+	case "json":
+		{
+			// Rows are written as objects, keeping the order of the columns
+			var content bytes.Buffer
+			content.WriteString("[")
+			for i, row := range result.Rows {
+				if i > 0 {
+					content.WriteString(",")
+				}
+				content.WriteString("\n  {")
+				for j, value := range row {
+					if j > 0 {
+						content.WriteString(", ")
+					}
+					column, _ := json.Marshal(result.Columns[j])
+					jsonValue, err := json.Marshal(value)
+					if err != nil {
+						return err
+					}
+					fmt.Fprintf(&content, "%s: %s", column, jsonValue)
+				}
+				content.WriteString("}")
+			}
+			if len(result.Rows) > 0 {
+				content.WriteString("\n")
+			}
+			content.WriteString("]")
+			fmt.Println(content.String())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"encoding/json"
+	"fmt"
+	"gristctl/gristapi"
+	"io"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Read a specification in JSON or YAML format from a file (or from stdin),
+// made of one object or of a list of objects
+func readSpecs[T any](fileName string) ([]T, error) {
+	file, err := openInput(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+
+	// YAML being a superset of JSON, both formats are read as YAML,
+	// then converted to JSON to be decoded with the JSON tags of the types
+	var spec any
+	if err := yaml.Unmarshal(content, &spec); err != nil {
+		return nil, fmt.Errorf("invalid specification: %w", err)
+	}
+	if _, isList := spec.([]any); !isList {
+		spec = []any{spec}
+	}
+	jsonSpec, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid specification: %w", err)
+	}
+	specs := []T{}
+	if err := json.Unmarshal(jsonSpec, &specs); err != nil {
+		return nil, fmt.Errorf("invalid specification: %w", err)
+	}
+	return specs, nil
+}
+
+// Creates the tables described in a JSON or YAML file (or stdin)
+func CreateTables(docId string, fileName string) error {
+	tables, err := readSpecs[gristapi.TableSchema](fileName)
+	if err != nil {
+		return err
+	}
+	for i, table := range tables {
+		if table.Id == "" {
+			return fmt.Errorf("table %d has no id", i+1)
+		}
+	}
+	ids, err := client.CreateTables(ctx, docId, tables)
+	if err != nil {
+		return entityError("document", docId, err)
+	}
+	fmt.Printf("Tables created : %s ✅\n", strings.Join(ids, ", "))
+	return nil
+}
+
+// Adds the columns described in a JSON or YAML file (or stdin) to a table
+func AddColumns(docId string, tableId string, fileName string) error {
+	columns, err := readSpecs[gristapi.Column](fileName)
+	if err != nil {
+		return err
+	}
+	ids, err := client.AddColumns(ctx, docId, tableId, columns)
+	if err != nil {
+		return entityError("table", tableId, err)
+	}
+	fmt.Printf("Columns added : %s ✅\n", strings.Join(ids, ", "))
+	return nil
+}
+
+// Modifies a column with the given properties, or the columns described
+// in a JSON or YAML file (or stdin) if columnId is empty
+// Setting a formula makes the column a formula column
+func ModifyColumns(docId string, tableId string, columnId string, fields gristapi.ColumnFields, fileName string) error {
+	columns := []gristapi.Column{}
+	if columnId != "" {
+		if fields.Formula != "" && fields.IsFormula == nil {
+			isFormula := true
+			fields.IsFormula = &isFormula
+		}
+		if reflect.DeepEqual(fields, gristapi.ColumnFields{}) {
+			return fmt.Errorf("nothing to modify, use --type, --label or --formula")
+		}
+		columns = append(columns, gristapi.Column{Id: columnId, Fields: fields})
+	} else {
+		var err error
+		if columns, err = readSpecs[gristapi.Column](fileName); err != nil {
+			return err
+		}
+	}
+	for i, column := range columns {
+		if column.Id == "" {
+			return fmt.Errorf("column %d has no id", i+1)
+		}
+	}
+	if err := client.UpdateColumns(ctx, docId, tableId, columns); err != nil {
+		return entityError("table", tableId, err)
+	}
+	fmt.Printf("%d columns modified ✅\n", len(columns))
+	return nil
+}
+
+// Deletes a column of a table
+func DeleteColumn(docId string, tableId string, columnId string) error {
+	if err := client.DeleteColumn(ctx, docId, tableId, columnId); err != nil {
+		return entityError("column", columnId, err)
+	}
+	fmt.Printf("Column %s deleted ✅\n", columnId)
+	return nil
+}
@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"gristctl/gristapi"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadSpecs(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		options string
+	}{
+		{"JSON string", `{"id": "Status", "fields": {"type": "Choice", "widgetOptions": "{\"choices\": [\"Open\"]}"}}`, `{"choices": ["Open"]}`},
+		{"YAML string", "id: Status\nfields:\n  widgetOptions: '{\"choices\": [\"Open\"]}'\n", `{"choices": ["Open"]}`},
+		{"YAML map", "id: Status\nfields:\n  widgetOptions:\n    choices:\n      - Open\n    alignment: left\n", `{"alignment":"left","choices":["Open"]}`},
+		{"no options", "id: Status\nfields:\n  type: Text\n", ""},
+	}
+	for _, test := range tests {
+		fileName := filepath.Join(t.TempDir(), "columns.yaml")
+		if err := os.WriteFile(fileName, []byte(test.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		columns, err := readSpecs[gristapi.Column](fileName)
+		if err != nil || len(columns) != 1 {
+			t.Errorf("%s: error reading the columns %v (%v)", test.name, columns, err)
+			continue
+		}
+		if options := columns[0].Fields.WidgetOptions; options != test.options {
+			t.Errorf("%s: unexpected widget options %s, expected %s", test.name, options, test.options)
+		}
+	}
+
+	fileName := filepath.Join(t.TempDir(), "columns.yaml")
+	os.WriteFile(fileName, []byte("id: Status\nfields:\n  widgetOptions: 3\n"), 0600)
+	if _, err := readSpecs[gristapi.Column](fileName); err == nil {
+		t.Error("Numeric widget options should be an error")
+	}
+}
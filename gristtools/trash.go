@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Displays the workspaces and documents of an organization which are in the trash
+func DisplayTrash(orgId int) error {
+	workspaces, err := client.GetTrash(ctx, orgId)
+	if err != nil {
+		return entityError("organization", orgId, err)
+	}
+
+	type trashItem struct {
+		Type          string `json:"type"`
+		Id            string `json:"id"`
+		Name          string `json:"name"`
+		WorkspaceId   int    `json:"workspaceId"`
+		WorkspaceName string `json:"workspaceName"`
+		RemovedAt     string `json:"removedAt"`
+	}
+	items := []trashItem{}
+	for _, workspace := range workspaces {
+		if workspace.RemovedAt != "" {
+			items = append(items, trashItem{"workspace", strconv.Itoa(workspace.Id), workspace.Name, workspace.Id, workspace.Name, workspace.RemovedAt})
+		}
+		for _, doc := range workspace.Docs {
+			// The documents of a removed workspace are restored with it
+			removedAt := doc.RemovedAt
+			if removedAt == "" {
+				removedAt = workspace.RemovedAt
+			}
+			items = append(items, trashItem{"doc", doc.Id, doc.Name, workspace.Id, workspace.Name, removedAt})
+		}
+	}
+
+	switch output {
+	case "table":
+		{
+			if len(items) == 0 {
+				fmt.Printf("The trash of organization %d is empty\n", orgId)
+				return nil
+			}
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Type", "Id", "Name", "Workspace", "Removed at"})
+			for _, item := range items {
+				table.Append([]string{item.Type, item.Id, item.Name, fmt.Sprintf("%s (%d)", item.WorkspaceName, item.WorkspaceId), item.RemovedAt})
+			}
+			table.Render()
+		}
+	case "json":
+		{
+			jsonItems, err := json.MarshalIndent(items, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(jsonItems))
+		}
+	}
+	return nil
+}
+
+// Restores a workspace from the trash, with its documents
+func UnremoveWorkspace(workspaceId int) error {
+	if err := client.UnremoveWorkspace(ctx, workspaceId); err != nil {
+		return entityError("workspace", workspaceId, err)
+	}
+	fmt.Printf("Workspace %d restored from the trash ✅\n", workspaceId)
+	return nil
+}
+
+// Restores a document from the trash
+func UnremoveDoc(docId string) error {
+	if err := client.UnremoveDoc(ctx, docId); err != nil {
+		return entityError("document", docId, err)
+	}
+	fmt.Printf("Document %s restored from the trash ✅\n", docId)
+	return nil
+}
@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: 2024 Ville Eurométropole Strasbourg
+//
+// SPDX-License-Identifier: MIT
+
+package gristtools
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Creates a workspace in an organization, unless a workspace with this name already exists
+func CreateWorkspace(orgId int, name string) error {
+	workspaceId, created, err := client.FindOrCreateWorkspace(ctx, orgId, name)
+	if err != nil {
+		return entityError("organization", orgId, err)
+	}
+	if created {
+		fmt.Printf("Workspace '%s' created with id %d ✅\n", name, workspaceId)
+	} else {
+		fmt.Printf("Workspace '%s' already exists with id %d ✅\n", name, workspaceId)
+	}
+	return nil
+}
+
+// Renames a workspace
+func RenameWorkspace(workspaceId int, name string) error {
+	if err := client.RenameWorkspace(ctx, workspaceId, name); err != nil {
+		return entityError("workspace", workspaceId, err)
+	}
+	fmt.Printf("Workspace %d renamed to '%s' ✅\n", workspaceId, name)
+	return nil
+}
+
+// Reads a list of names, one per line, ignoring empty lines and duplicates
+func readNames(fileName string) ([]string, error) {
+	file, err := openInput(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	names := []string{}
+	found := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name != "" && !found[name] {
+			found[name] = true
+			names = append(names, name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+	return names, nil
+}
+
+/*
+Creates the workspaces of an organization whose names are listed in a file
+(one name per line, stdin by default)
+
+The workspaces which already exist are kept, so that the command can be run
+again.
+*/
+func CreateWorkspaces(orgId int, fileName string) error {
+	names, err := readNames(fileName)
+	if err != nil {
+		return err
+	}
+
+	type workspaceResult struct {
+		Name    string `json:"name"`
+		Id      int    `json:"id,omitempty"`
+		Created bool   `json:"created"`
+		Error   string `json:"error,omitempty"`
+	}
+	results := []workspaceResult{}
+	nbErrors := 0
+	for _, name := range names {
+		result := workspaceResult{Name: name}
+		result.Id, result.Created, err = client.FindOrCreateWorkspace(ctx, orgId, name)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			result.Error = entityError("organization", orgId, err).Error()
+			nbErrors++
+		}
+		results = append(results, result)
+	}
+
+	switch output {
+	case "table":
+		{
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Workspace", "Id", "Status"})
+			for _, result := range results {
+				status := "already exists"
+				if result.Error != "" {
+					status = "❗️ " + result.Error
+				} else if result.Created {
+					status = "created ✅"
+				}
+				id := ""
+				if result.Id != 0 {
+					id = strconv.Itoa(result.Id)
+				}
+				table.Append([]string{result.Name, id, status})
+			}
+			table.Render()
+		}
+	case "json":
+		{
+			jsonResults, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(jsonResults))
+		}
+	}
+	if nbErrors > 0 {
+		return fmt.Errorf("%d of %d workspaces could not be created", nbErrors, len(names))
+	}
+	return nil
+}
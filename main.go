@@ -6,56 +6,206 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"gristctl/gristapi"
 	"gristctl/gristtools"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
 var version = "Undefined"
 
+// Parse the command line options, which can be placed before or after the command
+// Returns the command and its arguments
+func parseArgs() []string {
+	flag.Parse()
+	args := []string{}
+	for flag.NArg() > 0 {
+		args = append(args, flag.Arg(0))
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+	return args
+}
+
+// Returns a context canceled when the program receives SIGINT or SIGTERM
+func cancelOnSignal() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+		// Leave some time to abort the outstanding requests,
+		// then quit even if the program is waiting for an answer on stdin
+		time.Sleep(time.Second)
+		os.Exit(gristtools.ExitCanceled)
+	}()
+	return ctx
+}
+
+// Parse the id of a workspace, an organization or a user
+func parseId(entity string, value string) (int, error) {
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s id '%s'", entity, value)
+	}
+	return id, nil
+}
+
 func main() {
 	// Define the options
 	optionOutput := flag.String("o", "table", "Output format")
+	var optionFile string
+	flag.StringVar(&optionFile, "f", "", "File to read or write ('-' for standard input/output)")
+	flag.StringVar(&optionFile, "file", "", "File to read or write ('-' for standard input/output)")
+	optionRetries := flag.Int("retries", gristapi.DefaultRetryPolicy.MaxAttempts-1, "Number of retries of a failed request")
+	optionRetryWait := flag.Duration("retry-wait", gristapi.DefaultRetryPolicy.MinBackoff, "Wait before the first retry, doubled at each retry")
+	optionRetryMaxWait := flag.Duration("retry-max-wait", gristapi.DefaultRetryPolicy.MaxBackoff, "Maximum wait between two retries")
+	optionTimeout := flag.Duration("timeout", 0, "Maximum duration of the command (0 for no limit)")
+	optionProfile := flag.String("profile", "", "Configuration profile to use (default: $GRIST_PROFILE or the current profile)")
+	optionFilter := flag.String("filter", "", `Records filter, e.g. {"Status": ["Open", "New"]}`)
+	optionSort := flag.String("sort", "", "Columns to sort the records by, separated by commas ('-' prefix for descending order)")
+	optionLimit := flag.Int("limit", 0, "Maximum number of records (0 for no limit)")
+	optionKey := flag.String("key", "", "Columns identifying a record, separated by commas")
+	optionType := flag.String("type", "", "Type of the column (Text, Numeric, Int, Bool, Date, Ref:<table>...)")
+	optionLabel := flag.String("label", "", "Label of the column")
+	optionFormula := flag.String("formula", "", "Formula of the column")
+	optionDryRun := flag.Bool("dry-run", false, "Display the changes without applying them")
+	optionName := flag.String("name", "", "Name of the documents created by copy doc, import doc and restore")
+	optionWorkspace := flag.Int("workspace", 0, "Id of the workspace of the documents created by import doc and restore, or of the workspace migrated by migrate")
+	optionOrg := flag.String("org", "", "Id of the organization backed up by backup ('all' for every organization), whose trash is listed, or in which workspaces and documents are created by create workspaces, restore and migrate")
+	optionDest := flag.String("dest", "", "Directory of the backups")
+	optionConcurrency := flag.Int("concurrency", 4, "Number of documents processed at the same time by backup and purge")
+	optionIncremental := flag.Bool("incremental", false, "Only download the documents modified since the previous backup")
+	optionKeepDaily := flag.Int("keep-daily", 0, "Number of days whose last backup is kept")
+	optionKeepWeekly := flag.Int("keep-weekly", 0, "Number of weeks whose last backup is kept")
+	optionDoc := flag.String("doc", "", "Id of the document whose content is replaced by restore")
+	optionManifest := flag.String("manifest", "", "Manifest (or directory) of the backup to restore")
+	optionFrom := flag.String("from", "", "Profile of the server the workspace is migrated from (default: the current profile)")
+	optionTo := flag.String("to", "", "Profile of the server the workspace is migrated to")
+	optionState := flag.String("state", "", "State file of the migration (default: gristctl-migrate-<workspace id>.json)")
+	optionSizes := flag.Bool("sizes", false, "Display the size of the snapshots (downloaded to be measured)")
+	optionNoSizes := flag.Bool("no-sizes", false, "Do not measure the size of the purged documents (downloaded before and after the purge)")
+	optionYes := flag.Bool("yes", false, "Do not ask for confirmation")
+	optionPermanent := flag.Bool("permanent", false, "Delete for good, instead of moving to the trash")
+	optionPrune := flag.Bool("prune", false, "Remove what is not in the schema (schema apply) or the users which are not in the access policy (access plan/apply)")
+	optionTemplate := flag.Bool("template", false, "Copy the document as a template, without its data and history")
 
-	flag.Parse()
+	args := parseArgs()
 
 	switch *optionOutput {
 	case "json":
 		gristtools.SetOutput("json")
+	case "csv", "unified":
+		// Only the diff commands have csv and unified outputs
+		if len(args) > 0 && args[0] == "diff" {
+			gristtools.SetOutput(*optionOutput)
+		} else {
+			gristtools.SetOutput("table")
+		}
 	default:
 		gristtools.SetOutput("table")
 	}
 
-	args := flag.Args()
-
 	if len(args) < 1 {
 		gristtools.Help()
 	}
 
+	// The configuration commands can be used to create a missing profile,
+	// and the token is only resolved for the commands contacting the server
+	profile, err := gristapi.FindProfile(*optionProfile)
+	if args[0] != "config" && args[0] != "version" && args[0] != "help" {
+		if err == nil && profile.URL == "" {
+			err = fmt.Errorf("no Grist server configured, use 'gristctl config'")
+		}
+		if err == nil {
+			profile.Token, err = profile.GetToken()
+			err = gristtools.WarnTokenFallback(err)
+		}
+		gristtools.ExitOnError(err)
+	}
+	gristtools.SetProfile(*optionProfile)
+
+	retryPolicy := gristapi.DefaultRetryPolicy
+	retryPolicy.MaxAttempts = *optionRetries + 1
+	retryPolicy.MinBackoff = *optionRetryWait
+	retryPolicy.MaxBackoff = *optionRetryMaxWait
+	newClient := func(profile gristapi.Profile) *gristapi.Client {
+		return gristapi.NewClient(profile.URL, profile.Token,
+			gristapi.WithUserAgent("gristctl/"+version),
+			gristapi.WithRetryPolicy(retryPolicy))
+	}
+	client := newClient(profile)
+	gristtools.SetClient(client)
+
+	ctx := cancelOnSignal()
+	if *optionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *optionTimeout)
+		defer cancel()
+	}
+	gristtools.SetContext(ctx)
+
 	switch arg1 := args[0]; arg1 {
 	case "config":
-		gristtools.Config()
+		switch len(args) {
+		case 1:
+			err = gristtools.Config()
+		case 2:
+			switch args[1] {
+			case "list":
+				err = gristtools.ConfigList()
+			default:
+				gristtools.Help()
+			}
+		case 3:
+			switch args[1] {
+			case "add":
+				err = gristtools.ConfigAdd(args[2])
+			case "use":
+				err = gristtools.ConfigUse(args[2])
+			case "remove":
+				err = gristtools.ConfigRemove(args[2])
+			default:
+				gristtools.Help()
+			}
+		default:
+			gristtools.Help()
+		}
 	case "version":
 		gristtools.Version(version)
 	case "get":
 		{
 			if len(args) > 1 {
 				switch arg2 := args[1]; arg2 {
+				case "trash":
+					if len(args) == 2 && *optionOrg != "" {
+						var orgId int
+						if orgId, err = parseId("organization", *optionOrg); err == nil {
+							err = gristtools.DisplayTrash(orgId)
+						}
+					} else {
+						gristtools.Help()
+					}
 				case "org":
 					{
 						switch nb := len(args); nb {
 						case 2:
-							gristtools.DisplayOrgs()
+							err = gristtools.DisplayOrgs()
 						case 3:
 							orgId := args[2]
-							gristtools.DisplayOrg(orgId)
+							err = gristtools.DisplayOrg(orgId)
 						case 4:
 							switch args[3] {
 							case "access":
 								orgId := args[2]
-								gristtools.DisplayOrgAccess(orgId)
+								err = gristtools.DisplayOrgAccess(orgId)
 							default:
 								gristtools.Help()
 							}
@@ -69,25 +219,29 @@ func main() {
 						switch len(args) {
 						case 3:
 							docId := args[2]
-							gristtools.DisplayDoc(docId)
+							err = gristtools.DisplayDoc(docId)
 						case 4:
 							docId := args[2]
 							switch args[3] {
 							case "access":
-								gristtools.DisplayDocAccess(docId)
+								err = gristtools.DisplayDocAccess(docId)
 							case "grist":
-								gristtools.ExportDocGrist(docId)
+								err = gristtools.ExportDocGrist(docId, optionFile)
 							case "excel":
-								gristtools.ExportDocExcel(docId)
+								err = gristtools.ExportDocExcel(docId, optionFile)
+							case "states":
+								err = gristtools.DisplayDocStates(docId)
+							case "snapshots":
+								err = gristtools.DisplayDocSnapshots(docId, *optionSizes)
 							default:
-								fmt.Println("You have to choose between 'access', 'grist', or 'excel'")
+								fmt.Println("You have to choose between 'access', 'grist', 'excel', 'states' or 'snapshots'")
 							}
 						case 5:
 							docId := args[2]
 							switch args[3] {
 							case "table":
 								tableName := args[4]
-								gristapi.GetTableContent(docId, tableName)
+								err = gristtools.DisplayTableContent(docId, tableName)
 							default:
 								gristtools.Help()
 							}
@@ -100,15 +254,15 @@ func main() {
 					{
 						switch len(args) {
 						case 3:
-							worskspaceId, err := strconv.Atoi(args[2])
-							if err == nil {
-								gristtools.DisplayWorkspace(worskspaceId)
+							var workspaceId int
+							if workspaceId, err = parseId("workspace", args[2]); err == nil {
+								err = gristtools.DisplayWorkspace(workspaceId)
 							}
 						case 4:
 							if args[3] == "access" {
-								worskspaceId, err := strconv.Atoi(args[2])
-								if err == nil {
-									gristtools.DisplayWorkspaceAccess(worskspaceId)
+								var workspaceId int
+								if workspaceId, err = parseId("workspace", args[2]); err == nil {
+									err = gristtools.DisplayWorkspaceAccess(workspaceId)
 								}
 							}
 						default:
@@ -119,37 +273,179 @@ func main() {
 					{
 						switch len(args) {
 						case 2:
-							gristtools.DisplayUserMatrix()
+							err = gristtools.DisplayUserMatrix()
 						case 3:
-							userId, err := strconv.Atoi(args[2])
-							if err == nil {
-								gristtools.DisplayUser(userId)
+							var userId int
+							if userId, err = parseId("user", args[2]); err == nil {
+								err = gristtools.DisplayUser(userId)
 							}
 						default:
 							gristtools.Help()
 						}
 					}
+				case "records":
+					if len(args) == 4 {
+						err = gristtools.DisplayRecords(args[2], args[3], *optionFilter, *optionSort, *optionLimit)
+					} else {
+						gristtools.Help()
+					}
 				default:
 					gristtools.Help()
 				}
 			}
 		}
+	case "create":
+		if len(args) == 3 && args[1] == "table" {
+			err = gristtools.CreateTables(args[2], optionFile)
+		} else if len(args) == 4 && args[1] == "doc" {
+			var workspaceId int
+			if workspaceId, err = parseId("workspace", args[2]); err == nil {
+				err = gristtools.CreateDoc(workspaceId, args[3])
+			}
+		} else if len(args) == 4 && args[1] == "workspace" {
+			var orgId int
+			if orgId, err = parseId("organization", args[2]); err == nil {
+				err = gristtools.CreateWorkspace(orgId, args[3])
+			}
+		} else if len(args) == 2 && args[1] == "workspaces" && *optionOrg != "" {
+			var orgId int
+			if orgId, err = parseId("organization", *optionOrg); err == nil {
+				err = gristtools.CreateWorkspaces(orgId, optionFile)
+			}
+		} else {
+			gristtools.Help()
+		}
+	case "rename":
+		if len(args) == 4 && args[1] == "doc" {
+			err = gristtools.RenameDoc(args[2], args[3])
+		} else if len(args) == 4 && args[1] == "workspace" {
+			var workspaceId int
+			if workspaceId, err = parseId("workspace", args[2]); err == nil {
+				err = gristtools.RenameWorkspace(workspaceId, args[3])
+			}
+		} else {
+			gristtools.Help()
+		}
+	case "copy":
+		if len(args) > 3 && args[1] == "doc" {
+			workspaceIds := []int{}
+			for _, arg := range args[3:] {
+				workspaceId, errId := parseId("workspace", arg)
+				if errId != nil {
+					gristtools.ExitOnError(errId)
+				}
+				workspaceIds = append(workspaceIds, workspaceId)
+			}
+			err = gristtools.CopyDoc(args[2], workspaceIds, *optionName, *optionTemplate)
+		} else {
+			gristtools.Help()
+		}
+	case "move":
+		if len(args) == 4 && args[1] == "doc" {
+			var workspaceId int
+			if workspaceId, err = parseId("workspace", args[3]); err == nil {
+				err = gristtools.MoveDoc(args[2], workspaceId)
+			}
+		} else {
+			gristtools.Help()
+		}
+	case "add", "update", "upsert":
+		if len(args) == 4 && args[0] == "add" && args[1] == "column" {
+			err = gristtools.AddColumns(args[2], args[3], optionFile)
+		} else if len(args) == 4 && args[1] == "records" {
+			docId, tableId := args[2], args[3]
+			switch args[0] {
+			case "add":
+				err = gristtools.AddRecords(docId, tableId, optionFile)
+			case "update":
+				err = gristtools.UpdateRecords(docId, tableId, optionFile)
+			case "upsert":
+				keys := []string{}
+				if *optionKey != "" {
+					keys = strings.Split(*optionKey, ",")
+				}
+				err = gristtools.UpsertRecords(docId, tableId, optionFile, keys)
+			}
+		} else {
+			gristtools.Help()
+		}
+	case "modify":
+		if (len(args) == 4 || len(args) == 5) && args[1] == "column" {
+			columnId := ""
+			if len(args) == 5 {
+				columnId = args[4]
+			}
+			fields := gristapi.ColumnFields{Type: *optionType, Label: *optionLabel, Formula: *optionFormula}
+			err = gristtools.ModifyColumns(args[2], args[3], columnId, fields, optionFile)
+		} else {
+			gristtools.Help()
+		}
+	case "backup":
+		if len(args) == 1 && *optionOrg != "" && *optionDest != "" {
+			err = gristtools.Backup(*optionOrg, *optionDest, *optionConcurrency, *optionIncremental, *optionKeepDaily, *optionKeepWeekly)
+		} else {
+			gristtools.Help()
+		}
+	case "diff":
+		if len(args) == 4 && args[1] == "schema" {
+			err = gristtools.DiffSchema(args[2], args[3])
+		} else if len(args) == 4 && args[1] == "data" {
+			keys := []string{}
+			if *optionKey != "" {
+				keys = strings.Split(*optionKey, ",")
+			}
+			err = gristtools.DiffData(args[2], args[3], keys)
+		} else {
+			gristtools.Help()
+		}
+	case "schema":
+		switch {
+		case len(args) == 3 && args[1] == "export":
+			err = gristtools.ExportSchema(args[2], optionFile)
+		case len(args) == 3 && args[1] == "apply":
+			err = gristtools.ApplySchema(args[2], optionFile, *optionDryRun, *optionPrune, *optionYes)
+		default:
+			gristtools.Help()
+		}
+	case "sql":
+		// The query is read from the file given with -f if it is not in the arguments
+		switch {
+		case len(args) > 2 && optionFile == "":
+			err = gristtools.QuerySQL(args[1], args[2], args[3:], optionFile)
+		case len(args) > 1 && optionFile != "":
+			err = gristtools.QuerySQL(args[1], "", args[2:], optionFile)
+		default:
+			gristtools.Help()
+		}
 	case "purge":
 		{
 			if len(args) > 2 {
 				switch args[1] {
-				case "doc":
-					docId := args[2]
+				case "doc", "workspace", "org":
+					if len(args) > 4 {
+						gristtools.Help()
+					}
 					nbHisto := 3
 					if len(args) == 4 {
-						nb, err := strconv.Atoi(args[3])
-						if err == nil {
-							nbHisto = nb
-						} else {
-							gristtools.Help()
+						if nbHisto, err = strconv.Atoi(args[3]); err != nil || nbHisto < 1 {
+							err = fmt.Errorf("invalid number of states to keep '%s'", args[3])
+							break
+						}
+					}
+					switch args[1] {
+					case "doc":
+						err = gristtools.PurgeDoc(args[2], nbHisto, *optionYes, !*optionNoSizes)
+					case "workspace":
+						var workspaceId int
+						if workspaceId, err = parseId("workspace", args[2]); err == nil {
+							err = gristtools.PurgeWorkspace(workspaceId, nbHisto, *optionYes, *optionConcurrency, !*optionNoSizes)
+						}
+					case "org":
+						var orgId int
+						if orgId, err = parseId("organization", args[2]); err == nil {
+							err = gristtools.PurgeOrg(orgId, nbHisto, *optionYes, *optionConcurrency, !*optionNoSizes)
 						}
 					}
-					gristapi.PurgeDoc(docId, nbHisto)
 				default:
 					gristtools.Help()
 				}
@@ -161,18 +457,18 @@ func main() {
 				switch arg2 := args[1]; arg2 {
 				case "workspace":
 					if len(args) == 3 {
-						idWorkspace, err := strconv.Atoi(args[2])
-						if err == nil {
-							gristtools.DeleteWorkspace(idWorkspace)
+						var workspaceId int
+						if workspaceId, err = parseId("workspace", args[2]); err == nil {
+							err = gristtools.DeleteWorkspace(workspaceId, *optionPermanent)
 						}
 					} else {
 						gristtools.Help()
 					}
 				case "user":
 					if len(args) == 3 {
-						idUser, err := strconv.Atoi(args[2])
-						if err == nil {
-							gristtools.DeleteUser(idUser)
+						var userId int
+						if userId, err = parseId("user", args[2]); err == nil {
+							err = gristtools.DeleteUser(userId)
 						}
 					} else {
 						gristtools.Help()
@@ -180,18 +476,98 @@ func main() {
 				case "doc":
 					if len(args) == 3 {
 						docId := args[2]
-						gristtools.DeleteDoc(docId)
+						err = gristtools.DeleteDoc(docId, *optionPermanent)
+					}
+				case "column":
+					if len(args) == 5 {
+						err = gristtools.DeleteColumn(args[2], args[3], args[4])
+					} else {
+						gristtools.Help()
+					}
+				case "records":
+					if len(args) > 3 {
+						err = gristtools.DeleteRecords(args[2], args[3], args[4:], optionFile, *optionYes)
+					} else {
+						gristtools.Help()
 					}
 				default:
 					gristtools.Help()
 				}
 			}
 		}
+	case "migrate":
+		if len(args) == 1 && *optionTo != "" && *optionWorkspace > 0 {
+			orgId := 0
+			if *optionOrg != "" {
+				orgId, err = parseId("organization", *optionOrg)
+			}
+			source, target := client, (*gristapi.Client)(nil)
+			if err == nil && *optionFrom != "" {
+				var sourceProfile gristapi.Profile
+				sourceProfile, err = gristapi.LoadProfile(*optionFrom)
+				if err = gristtools.WarnTokenFallback(err); err == nil {
+					source = newClient(sourceProfile)
+				}
+			}
+			if err == nil {
+				var targetProfile gristapi.Profile
+				targetProfile, err = gristapi.LoadProfile(*optionTo)
+				if err = gristtools.WarnTokenFallback(err); err == nil {
+					target = newClient(targetProfile)
+				}
+			}
+			if err == nil {
+				err = gristtools.Migrate(source, target, *optionWorkspace, orgId, *optionState)
+			}
+		} else {
+			gristtools.Help()
+		}
+	case "access":
+		if len(args) == 2 && args[1] == "plan" {
+			err = gristtools.PlanAccess(optionFile, *optionPrune)
+		} else if len(args) == 2 && args[1] == "apply" {
+			err = gristtools.ApplyAccess(optionFile, *optionPrune, *optionYes)
+		} else if len(args) == 6 && args[1] == "set" {
+			err = gristtools.SetAccess(args[2], args[3], args[4], args[5])
+		} else if len(args) == 5 && args[1] == "revoke" {
+			err = gristtools.RevokeAccess(args[2], args[3], args[4])
+		} else if len(args) == 5 && args[1] == "set-inheritance" {
+			err = gristtools.SetInheritance(args[2], args[3], args[4])
+		} else {
+			gristtools.Help()
+		}
+	case "restore":
+		if len(args) == 3 && args[1] == "doc" {
+			err = gristtools.UnremoveDoc(args[2])
+		} else if len(args) == 3 && args[1] == "workspace" {
+			var workspaceId int
+			if workspaceId, err = parseId("workspace", args[2]); err == nil {
+				err = gristtools.UnremoveWorkspace(workspaceId)
+			}
+		} else if len(args) == 1 && *optionManifest != "" {
+			orgId := 0
+			if *optionOrg != "" {
+				orgId, err = parseId("organization", *optionOrg)
+			}
+			if err == nil {
+				err = gristtools.RestoreBackup(*optionManifest, orgId, *optionDryRun)
+			}
+		} else if len(args) == 2 && (*optionWorkspace > 0 || *optionDoc != "") {
+			err = gristtools.RestoreDoc(args[1], *optionWorkspace, *optionDoc, *optionName, *optionYes)
+		} else {
+			gristtools.Help()
+		}
 	case "import":
 		if len(args) > 1 {
 			switch args[1] {
 			case "users":
 				gristtools.ImportUsers()
+			case "doc":
+				if len(args) == 3 && *optionWorkspace > 0 {
+					err = gristtools.ImportDoc(args[2], *optionWorkspace, *optionName)
+				} else {
+					gristtools.Help()
+				}
 			default:
 				gristtools.Help()
 			}
@@ -201,4 +577,5 @@ func main() {
 		flag.PrintDefaults()
 	}
 
+	gristtools.ExitOnError(err)
 }